@@ -1,6 +1,6 @@
 export function setupEditor(
   textareaId: string,
-  onEvaluate: (code: string) => void
+  onEvaluate: (code: string, line: number, col: number) => void
 ) {
   const textarea = document.getElementById(textareaId) as HTMLTextAreaElement;
   if (!textarea || !(textarea instanceof HTMLTextAreaElement)) {
@@ -46,6 +46,17 @@ export function setupEditor(
 
   textarea.addEventListener("input", saveContent);
 
+  // Find the 1-based line and column of an offset in the textarea, counting
+  // columns in characters like the forth lexer does
+  const position = (offset: number) => {
+    const before = textarea.value.substring(0, offset);
+    const lineStart = before.lastIndexOf("\n") + 1;
+    return {
+      line: before.split("\n").length,
+      col: Array.from(before.substring(lineStart)).length + 1,
+    };
+  };
+
   // Handle shift+enter and tab
   textarea.addEventListener("keydown", (e: KeyboardEvent) => {
     if (e.key === "Enter" && e.shiftKey) {
@@ -55,8 +66,10 @@ export function setupEditor(
         textarea.selectionEnd
       );
       if (selectedText) {
-        // If there's selected text, evaluate that
-        onEvaluate(selectedText.trim());
+        // If there's selected text, evaluate that where it starts so errors
+        // point at the editor's lines
+        const { line, col } = position(textarea.selectionStart);
+        onEvaluate(selectedText, line, col);
       } else {
         // Otherwise, get and evaluate the current line
         const value = textarea.value;
@@ -66,7 +79,7 @@ export function setupEditor(
           start,
           end === -1 ? value.length : end
         );
-        onEvaluate(currentLine.trim());
+        onEvaluate(currentLine, position(start).line, 1);
       }
     }
     // Handle tab key
@@ -59,14 +59,14 @@ eventSource.onmessage = (event) => {
 const forthOutput = document.getElementById("forth-output") as HTMLDivElement;
 
 // Set up the editor with evaluation handler
-setupEditor("forth-input", async (code) => {
+setupEditor("forth-input", async (code, line, col) => {
   try {
     const response = await fetch("http://localhost:8080/evaluate", {
       method: "POST",
       headers: {
         "Content-Type": "application/json",
       },
-      body: JSON.stringify({ input: code }),
+      body: JSON.stringify({ input: code, line, col }),
     });
 
     const result = await response.json();
@@ -17,6 +17,16 @@ export interface MemoryObject {
   // Connection coordinates (for linked structures)
   connectsToX?: number | null;
   connectsToY?: number | null;
+
+  // First nod of the chain a subroutine nod plays (nods only)
+  callsToX?: number | null;
+  callsToY?: number | null;
+
+  // Launch actions waiting for the next quantum boundary (heds only)
+  pending?: string[];
+
+  // Symbolic name given with hed-name or nod-name
+  name?: string;
 }
 
 /**
@@ -25,6 +35,51 @@ export interface MemoryObject {
 export interface MemoryState {
   // Array of all objects currently in memory
   objects: MemoryObject[];
+
+  // Labelled rectangles of the grid
+  regions?: MemoryRegion[];
+}
+
+/**
+ * A problem found in the link graph, returned by /analyze
+ */
+export interface AnalysisIssue {
+  kind: "stale-link" | "unreachable" | "cycle" | "last-unreachable" | "no-first";
+  id: string;
+  type: "nod" | "hed";
+  message: string;
+}
+
+/**
+ * Report on the link graph of the grid, returned by /analyze
+ */
+export interface Analysis {
+  nods: number;
+  heds: number;
+  reachable: number;
+  issues: AnalysisIssue[];
+}
+
+/**
+ * Documentation for a dictionary word, returned by /words
+ */
+export interface WordInfo {
+  name: string;
+  effect?: string;
+  description?: string;
+  category: string;
+  source?: string;
+}
+
+/**
+ * A labelled rectangle of the grid
+ */
+export interface MemoryRegion {
+  name: string;
+  x: number;
+  y: number;
+  width: number;
+  height: number;
 }
 
 /**
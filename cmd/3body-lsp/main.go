@@ -0,0 +1,35 @@
+// Command 3body-lsp is a language server for 3body forth. It talks JSON-RPC
+// over stdin and stdout, offering completion and hover docs for dictionary
+// words, diagnostics for unknown words and unbalanced [ ] { } and : ;, and
+// a 3body.evaluate command that sends source to a running 3body server.
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	live := flag.String("server", "http://localhost:8080", "running 3body server to fetch words from and evaluate on, empty to work offline")
+	var preludes []string
+	flag.Func("prelude", "forth file whose definitions are offered as words, may be repeated", func(path string) error {
+		preludes = append(preludes, path)
+		return nil
+	})
+	flag.Parse()
+
+	// stdout carries the protocol so logs go to stderr
+	log.SetOutput(os.Stderr)
+	log.SetPrefix("3body-lsp: ")
+
+	s, err := newServer(newConn(os.Stdin, os.Stdout), strings.TrimSpace(*live), preludes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := s.run(); err != nil && err != io.EOF {
+		log.Fatal(err)
+	}
+}
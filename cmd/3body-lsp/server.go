@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"3body/forth"
+	"3body/world"
+)
+
+// evaluateCommand sends source to the running 3body server
+const evaluateCommand = "3body.evaluate"
+
+// server answers LSP requests for 3body forth documents
+type server struct {
+	conn     *conn
+	live     string   // Base URL of the running 3body server, "" to work offline
+	preludes []string // Files whose definitions are offered alongside the dictionary
+	http     *http.Client
+
+	builtin  forth.WordDocs // Words built into 3body
+	words    forth.WordDocs // builtin plus prelude and live words
+	docs     map[string]string
+	shutdown bool
+}
+
+func newServer(c *conn, live string, preludes []string) (*server, error) {
+	builtin := make(forth.WordDocs)
+	for _, info := range forth.CreateInitialState().Words() {
+		builtin[info.Name] = info
+	}
+	worldDocs, err := world.Docs()
+	if err != nil {
+		return nil, err
+	}
+	for name, info := range worldDocs {
+		builtin[name] = info
+	}
+
+	return &server{
+		conn:     c,
+		live:     strings.TrimRight(live, "/"),
+		preludes: preludes,
+		http:     &http.Client{Timeout: 5 * time.Second},
+		builtin:  builtin,
+		docs:     make(map[string]string),
+	}, nil
+}
+
+// run handles messages until the client exits
+func (s *server) run() error {
+	for {
+		msg, err := s.conn.read()
+		if respErr, ok := err.(*responseError); ok {
+			s.conn.reply(nil, nil, respErr)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		result, err := s.handle(msg)
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return nil
+			}
+			return fmt.Errorf("exit without shutdown")
+		}
+		if msg.ID != nil {
+			if err := s.conn.reply(msg.ID, result, err); err != nil {
+				return err
+			}
+		} else if err != nil {
+			log.Printf("%s: %v", msg.Method, err)
+		}
+	}
+}
+
+func (s *server) handle(msg *message) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		s.refreshWords()
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": map[string]interface{}{
+					"openClose": true,
+					"change":    textDocumentSyncAll,
+					"save":      map[string]interface{}{},
+				},
+				"completionProvider":     map[string]interface{}{},
+				"hoverProvider":          true,
+				"executeCommandProvider": map[string]interface{}{"commands": []string{evaluateCommand}},
+			},
+			"serverInfo": map[string]string{"name": "3body-lsp"},
+		}, nil
+
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "exit":
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.docs[params.TextDocument.URI] = params.TextDocument.Text
+		return nil, s.publishDiagnostics(params.TextDocument.URI)
+
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		// Full sync, the last change holds the whole document
+		if n := len(params.ContentChanges); n > 0 {
+			s.docs[params.TextDocument.URI] = params.ContentChanges[n-1].Text
+		}
+		return nil, s.publishDiagnostics(params.TextDocument.URI)
+
+	case "textDocument/didSave":
+		// Saving a prelude or defining words on the server changes what is known
+		s.refreshWords()
+		return nil, s.publishAll()
+
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []diagnostic{},
+		})
+
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.complete(params.TextDocument.URI), nil
+
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.hover(params), nil
+
+	case "workspace/executeCommand":
+		var params executeCommandParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.executeCommand(params)
+	}
+
+	if msg.ID == nil {
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+}
+
+func invalidParams(err error) error {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+// refreshWords rebuilds the known words from the built in dictionary, the
+// preludes and the live server. The live server has the final say since it
+// holds what is actually defined.
+func (s *server) refreshWords() {
+	words := make(forth.WordDocs, len(s.builtin))
+	for name, info := range s.builtin {
+		words[name] = info
+	}
+
+	for _, path := range s.preludes {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Error reading prelude: %v", err)
+			continue
+		}
+		for _, info := range forth.DocumentSource(string(src)) {
+			words[info.Name] = info
+		}
+	}
+
+	if s.live != "" {
+		live, err := s.fetchWords()
+		if err != nil {
+			log.Printf("Error fetching words from %s: %v", s.live, err)
+		}
+		for _, info := range live {
+			words[info.Name] = info
+		}
+	}
+
+	s.words = words
+}
+
+// fetchWords asks the running server for its dictionary
+func (s *server) fetchWords() ([]forth.WordInfo, error) {
+	resp, err := s.http.Get(s.live + "/words")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server replied %s", resp.Status)
+	}
+	var words []forth.WordInfo
+	if err := json.NewDecoder(resp.Body).Decode(&words); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// lookup finds a word, preferring definitions in the document itself
+func (s *server) lookup(uri, name string) (forth.WordInfo, bool) {
+	for _, info := range forth.DocumentSource(s.docs[uri]) {
+		if info.Name == name {
+			return info, true
+		}
+	}
+	info, ok := s.words[name]
+	return info, ok
+}
+
+func (s *server) publishAll() error {
+	for uri := range s.docs {
+		if err := s.publishDiagnostics(uri); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *server) publishDiagnostics(uri string) error {
+	text := s.docs[uri]
+	lines := strings.Split(text, "\n")
+
+	diagnostics := []diagnostic{}
+	for _, p := range forth.Check(text, func(name string) bool { _, ok := s.words[name]; return ok }) {
+		line := ""
+		if p.Line-1 < len(lines) {
+			line = lines[p.Line-1]
+		}
+		diagnostics = append(diagnostics, diagnostic{
+			Range: lspRange{
+				Start: position{Line: p.Line - 1, Character: utf16Column(line, p.Col)},
+				End:   position{Line: p.Line - 1, Character: utf16Column(line, p.EndCol)},
+			},
+			Severity: severityError,
+			Source:   "3body",
+			Message:  p.Msg,
+		})
+	}
+
+	return s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+// complete offers every known word, editors filter them by what has been
+// typed
+func (s *server) complete(uri string) []completionItem {
+	words := make(forth.WordDocs, len(s.words))
+	for name, info := range s.words {
+		words[name] = info
+	}
+	for _, info := range forth.DocumentSource(s.docs[uri]) {
+		words[info.Name] = info
+	}
+
+	items := make([]completionItem, 0, len(words))
+	for _, info := range words {
+		kind := completionKindWord
+		if info.Category == forth.CategoryUser {
+			kind = completionKindUser
+		}
+		items = append(items, completionItem{
+			Label:         info.Name,
+			Kind:          kind,
+			Detail:        info.Effect,
+			Documentation: &markupContent{Kind: "markdown", Value: describe(info)},
+		})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items
+}
+
+// hover documents the word under the cursor
+func (s *server) hover(params textDocumentPositionParams) *hover {
+	text := s.docs[params.TextDocument.URI]
+	lines := strings.Split(text, "\n")
+	if params.Position.Line >= len(lines) {
+		return nil
+	}
+	line := lines[params.Position.Line]
+	col := runeColumn(line, params.Position.Character)
+
+	// A document with a lex error is still lexed up to the error
+	tokens, _ := forth.Lex(text)
+	for _, tok := range tokens {
+		length := utf8.RuneCountInString(tok.Text)
+		if tok.Line != params.Position.Line+1 || col < tok.Col || col > tok.Col+length {
+			continue
+		}
+		if tok.Kind != forth.TokenWord && tok.Kind != forth.TokenBracket {
+			return nil
+		}
+
+		info, ok := s.lookup(params.TextDocument.URI, tok.Text)
+		if !ok {
+			return nil
+		}
+		return &hover{
+			Contents: markupContent{Kind: "markdown", Value: describe(info)},
+			Range: &lspRange{
+				Start: position{Line: params.Position.Line, Character: utf16Column(line, tok.Col)},
+				End:   position{Line: params.Position.Line, Character: utf16Column(line, tok.Col+length)},
+			},
+		}
+	}
+	return nil
+}
+
+// describe documents a word in markdown
+func describe(info forth.WordInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s**", info.Name)
+	if info.Effect != "" {
+		fmt.Fprintf(&b, " `%s`", info.Effect)
+	}
+	if info.Description != "" {
+		fmt.Fprintf(&b, "\n\n%s", info.Description)
+	}
+	if info.Source != "" {
+		fmt.Fprintf(&b, "\n\n```forth\n%s\n```", info.Source)
+	}
+	fmt.Fprintf(&b, "\n\n*%s*", info.Category)
+	return b.String()
+}
+
+// evaluateResult is what the server sends back from /evaluate
+type evaluateResult struct {
+	Output []string    `json:"output"`
+	Stack  forth.Stack `json:"stack"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// executeCommand runs 3body.evaluate, which takes the source to evaluate as
+// its only argument, usually the selected region
+func (s *server) executeCommand(params executeCommandParams) (interface{}, error) {
+	if params.Command != evaluateCommand {
+		return nil, &responseError{Code: codeInvalidParams, Message: "unknown command " + params.Command}
+	}
+	if s.live == "" {
+		return nil, fmt.Errorf("no 3body server to evaluate on, start with -server")
+	}
+
+	var source string
+	if len(params.Arguments) != 1 || json.Unmarshal(params.Arguments[0], &source) != nil {
+		return nil, &responseError{Code: codeInvalidParams, Message: evaluateCommand + " takes the source to evaluate"}
+	}
+
+	body, err := json.Marshal(map[string]string{"input": source})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.http.Post(s.live+"/evaluate", "application/json", bytes.NewReader(body))
+	if err != nil {
+		s.conn.notify("window/showMessage", showMessageParams{Type: messageTypeError, Message: err.Error()})
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server replied %s", resp.Status)
+	}
+	var result evaluateResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Output) > 0 {
+		s.conn.notify("window/showMessage", showMessageParams{Type: messageTypeInfo, Message: strings.Join(result.Output, "\n")})
+	}
+
+	// Evaluating may have defined words
+	s.refreshWords()
+	return result, s.publishAll()
+}
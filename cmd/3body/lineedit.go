@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// errInterrupted is returned by readLine when ctrl-c is pressed
+var errInterrupted = errors.New("interrupted")
+
+// lineEditor reads lines from a terminal with cursor movement and history.
+// When the input isn't a terminal it reads plain lines.
+type lineEditor struct {
+	in      *os.File
+	reader  *bufio.Reader
+	out     io.Writer
+	history []string
+	file    *os.File // Where history is saved, nil for none
+
+	mu      sync.Mutex // Guards the fields below, which printAbove redraws
+	editing bool
+	prompt  string
+	buf     []rune
+	pos     int
+}
+
+// newLineEditor creates an editor, loading history from historyPath if it
+// is not empty
+func newLineEditor(in *os.File, out io.Writer, historyPath string) *lineEditor {
+	e := &lineEditor{in: in, reader: bufio.NewReader(in), out: out}
+	if historyPath == "" {
+		return e
+	}
+
+	if data, err := os.ReadFile(historyPath); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line != "" {
+				e.history = append(e.history, line)
+			}
+		}
+	}
+	if f, err := os.OpenFile(historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600); err == nil {
+		e.file = f
+	}
+	return e
+}
+
+func (e *lineEditor) close() {
+	if e.file != nil {
+		e.file.Close()
+	}
+}
+
+// addHistory remembers a line for the up and down keys
+func (e *lineEditor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+	if e.file != nil {
+		fmt.Fprintln(e.file, line)
+	}
+}
+
+// readLine shows the prompt and reads a line. It returns io.EOF on ctrl-d at
+// the start of an empty line and errInterrupted on ctrl-c.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(int(e.in.Fd()))
+	if err != nil {
+		return e.readPlainLine(prompt)
+	}
+	defer restore()
+
+	e.mu.Lock()
+	e.editing, e.prompt, e.buf, e.pos = true, prompt, nil, 0
+	e.redraw()
+	e.mu.Unlock()
+
+	defer func() {
+		e.mu.Lock()
+		e.editing = false
+		e.mu.Unlock()
+	}()
+
+	// index is the history entry being shown, len(history) is the new line
+	index := len(e.history)
+	var draft []rune
+
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		e.mu.Lock()
+		switch r {
+		case '\r', '\n':
+			line := string(e.buf)
+			fmt.Fprint(e.out, "\n")
+			e.mu.Unlock()
+			e.addHistory(line)
+			return line, nil
+
+		case 3: // ctrl-c
+			fmt.Fprint(e.out, "^C\n")
+			e.mu.Unlock()
+			return "", errInterrupted
+
+		case 4: // ctrl-d
+			if len(e.buf) == 0 {
+				fmt.Fprint(e.out, "\n")
+				e.mu.Unlock()
+				return "", io.EOF
+			}
+			e.deleteAt(e.pos)
+
+		case 127, 8: // backspace
+			if e.pos > 0 {
+				e.pos--
+				e.deleteAt(e.pos)
+			}
+
+		case 1: // ctrl-a
+			e.pos = 0
+		case 5: // ctrl-e
+			e.pos = len(e.buf)
+		case 2: // ctrl-b
+			e.move(-1)
+		case 6: // ctrl-f
+			e.move(1)
+		case 11: // ctrl-k
+			e.buf = e.buf[:e.pos]
+		case 21: // ctrl-u
+			e.buf, e.pos = append([]rune{}, e.buf[e.pos:]...), 0
+		case 12: // ctrl-l
+			fmt.Fprint(e.out, "\033[H\033[2J")
+
+		case 27: // escape sequences for the arrow, home, end and delete keys
+			e.mu.Unlock()
+			seq := e.readEscape()
+			e.mu.Lock()
+			switch seq {
+			case "[A", "OA":
+				if index > 0 {
+					if index == len(e.history) {
+						draft = e.buf
+					}
+					index--
+					e.buf = []rune(e.history[index])
+					e.pos = len(e.buf)
+				}
+			case "[B", "OB":
+				if index < len(e.history) {
+					index++
+					if index == len(e.history) {
+						e.buf = draft
+					} else {
+						e.buf = []rune(e.history[index])
+					}
+					e.pos = len(e.buf)
+				}
+			case "[C", "OC":
+				e.move(1)
+			case "[D", "OD":
+				e.move(-1)
+			case "[H", "OH", "[1~":
+				e.pos = 0
+			case "[F", "OF", "[4~":
+				e.pos = len(e.buf)
+			case "[3~":
+				e.deleteAt(e.pos)
+			}
+
+		default:
+			if r >= ' ' {
+				e.buf = append(e.buf[:e.pos], append([]rune{r}, e.buf[e.pos:]...)...)
+				e.pos++
+			}
+		}
+		e.redraw()
+		e.mu.Unlock()
+	}
+}
+
+// readEscape reads what follows an escape, such as [A for the up arrow
+func (e *lineEditor) readEscape() string {
+	var seq []rune
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return string(seq)
+		}
+		seq = append(seq, r)
+		// Sequences end with a letter or ~, after the [ or O that opens them
+		if len(seq) > 1 && (r == '~' || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z')) {
+			return string(seq)
+		}
+	}
+}
+
+func (e *lineEditor) deleteAt(i int) {
+	if i < len(e.buf) {
+		e.buf = append(e.buf[:i], e.buf[i+1:]...)
+	}
+}
+
+func (e *lineEditor) move(by int) {
+	e.pos = max(0, min(len(e.buf), e.pos+by))
+}
+
+// redraw rewrites the prompt and line and puts the cursor back, the caller
+// holds e.mu
+func (e *lineEditor) redraw() {
+	fmt.Fprintf(e.out, "\r\033[K%s%s", e.prompt, string(e.buf))
+	if back := len(e.buf) - e.pos; back > 0 {
+		fmt.Fprintf(e.out, "\033[%dD", back)
+	}
+}
+
+// readPlainLine reads a line when the input isn't a terminal
+func (e *lineEditor) readPlainLine(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	line, err := e.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	e.addHistory(line)
+	return line, nil
+}
+
+// printAbove prints text without disturbing a line being edited, so events
+// can arrive while the user types
+func (e *lineEditor) printAbove(text string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.editing {
+		fmt.Fprintln(e.out, text)
+		return
+	}
+	fmt.Fprintf(e.out, "\r\033[K%s\n", text)
+	e.redraw()
+}
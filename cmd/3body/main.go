@@ -0,0 +1,54 @@
+// Command 3body runs the 3body world.
+//
+//	3body serve [flags]               start the HTTP server for the browser client
+//	3body repl [flags]                start an interactive session in the terminal
+//	3body run [flags] patch.fs        run a script on a simulated clock
+//
+// With no command it serves. Run 3body <command> -h for each command's flags.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		command, args = args[0], args[1:]
+	}
+
+	var err error
+	switch command {
+	case "serve":
+		err = serve(args)
+	case "repl":
+		err = runREPL(args)
+	case "run":
+		err = run(args)
+	case "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "3body: unknown command %q\n\n", command)
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "3body %s: %v\n", command, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `usage: 3body <command> [flags]
+
+commands:
+  serve              start the HTTP server for the browser client (default)
+  repl               start an interactive session in the terminal
+  run patch.fs       run a script on a simulated clock and record what it sends
+
+Run 3body <command> -h for each command's flags.
+`)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"3body/connections"
+	"3body/forth"
+	"3body/world"
+
+	"github.com/hypebeast/go-osc/osc"
+)
+
+// runREPL runs the world with an interactive session in the terminal.
+// Definitions, blocks, arrays and strings left open carry on to the next
+// line, ctrl-c stops a running evaluation and hed errors are shown as they
+// happen.
+func runREPL(args []string) error {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	historyPath := fs.String("history", defaultHistoryPath(), "file to keep input history in, empty for none")
+	showOutput := fs.Bool("output", false, "show what nod messages print as well as hed errors")
+	wf := addWorldFlags(fs)
+	fs.Parse(args)
+
+	host, port, err := splitHostPort(wf.osc)
+	if err != nil {
+		return err
+	}
+
+	clock := world.NewClock(wf.tick)
+	memory, s, err := newWorld(wf.rows, wf.cols, clock, osc.NewClient(host, port), wf.prelude, wf.roots)
+	if err != nil {
+		return err
+	}
+	drainGraphics()
+	clock.Start(memory)
+	wf.startWatching(s)
+
+	ed := newLineEditor(os.Stdin, os.Stdout, *historyPath)
+	defer ed.close()
+
+	events, stop := connections.Subscribe()
+	defer stop()
+	go func() {
+		for e := range events {
+			for _, line := range e.Lines {
+				switch {
+				case e.Type == connections.EventError && e.File != "":
+					ed.printAbove(fmt.Sprintf("! reloading %s: %s", e.File, line))
+				case e.Type == connections.EventError:
+					ed.printAbove(fmt.Sprintf("! hed %s: %s", e.Hed, line))
+				case e.Type == connections.EventOutput && forth.IsErrorLine(line):
+					ed.printAbove(fmt.Sprintf("! hed %s nod %s: %s", e.Hed, e.Nod, line))
+				case e.Type == connections.EventOutput && *showOutput:
+					ed.printAbove(fmt.Sprintf("  hed %s nod %s: %s", e.Hed, e.Nod, line))
+				}
+			}
+			if e.Type == connections.EventReload {
+				ed.printAbove("reloaded " + e.File)
+			}
+		}
+	}()
+
+	fmt.Printf("3body %dx%d, ctrl-d to quit\n", wf.rows, wf.cols)
+
+	var pending []string
+	for {
+		prompt := "> "
+		if len(pending) > 0 {
+			prompt = ". "
+		}
+
+		line, err := ed.readLine(prompt)
+		if err == errInterrupted {
+			pending = nil
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		pending = append(pending, line)
+		source := strings.Join(pending, "\n")
+		if incomplete(source) {
+			continue
+		}
+		pending = nil
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+
+		// The terminal is back in its normal mode while evaluating so
+		// ctrl-c arrives as a signal
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		stack, output, err := s.Evaluate(ctx, source)
+		cancel()
+
+		for _, line := range output {
+			ed.printAbove(line)
+		}
+		if err != nil {
+			ed.printAbove("Error: " + err.Error())
+		}
+		ed.printAbove(fmt.Sprintf("ok %v", []forth.StackItem(stack)))
+	}
+}
+
+// incomplete reports whether source stops inside a definition, block, array,
+// string or comment, so the REPL should read another line before evaluating
+func incomplete(source string) bool {
+	tokens, err := forth.Lex(source)
+	if _, ok := err.(*forth.LexError); ok {
+		// Lexing only fails on strings and comments that are never closed
+		return true
+	}
+
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.Kind != forth.TokenWord && tok.Kind != forth.TokenBracket {
+			continue
+		}
+		switch tok.Text {
+		case "postpone":
+			// The word postponed doesn't open or close anything
+			i++
+		case "[", "{", ":":
+			depth++
+		case "]", "}", ";":
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".3body_history")
+}
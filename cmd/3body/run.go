@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"3body/connections"
+	"3body/world"
+)
+
+// run loads a script and runs the world on a simulated clock as fast as it
+// can, writing every message, output line and error as a JSON line
+func run(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	ticks := fs.Int("ticks", 100, "ticks to run the clock for")
+	eventsPath := fs.String("events", "-", "file to write events to as JSON lines, - for stdout")
+	rows := fs.Int("rows", 20, "rows in the grid")
+	cols := fs.Int("cols", 20, "columns in the grid")
+	var prelude, roots []string
+	addPreludeFlag(fs, &prelude)
+	addRootFlag(fs, &roots)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: 3body run [flags] patch.fs")
+		fs.PrintDefaults()
+	}
+
+	// Flags may come before or after the script
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no script to run")
+	}
+	script := fs.Arg(0)
+	fs.Parse(fs.Args()[1:])
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	src, err := os.ReadFile(script)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *eventsPath != "-" {
+		f, err := os.Create(*eventsPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	// Messages are recorded rather than sent anywhere
+	clock := world.NewSimulatedClock()
+	memory, s, err := newWorld(*rows, *cols, clock, nil, prelude, roots)
+	if err != nil {
+		return err
+	}
+	drainGraphics()
+
+	events, stop := connections.SubscribeAll()
+	written := make(chan error)
+	count := 0
+	go func() {
+		enc := json.NewEncoder(out)
+		var err error
+		for e := range events {
+			if err == nil {
+				err = enc.Encode(e)
+			}
+			count++
+		}
+		written <- err
+	}()
+
+	clock.Start(memory)
+	_, output, err := s.Evaluate(context.Background(), string(src))
+	for _, line := range output {
+		fmt.Fprintln(os.Stderr, line)
+	}
+	if err != nil {
+		stop()
+		<-written
+		return fmt.Errorf("%s: %w", script, err)
+	}
+
+	for i := 0; i < *ticks; i++ {
+		clock.Advance()
+	}
+
+	stop()
+	if err := <-written; err != nil {
+		return fmt.Errorf("writing events: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "ran %s for %d ticks, %d events\n", script, *ticks, count)
+	return nil
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"3body/connections"
 	"3body/forth"
+	"3body/repl"
 	"3body/world"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -16,6 +18,10 @@ import (
 // Original structs
 type ForthRequest struct {
 	Input string `json:"input"`
+	// Where input starts in the editor, so errors point at the editor's
+	// lines. Both are 1-based and default to 1.
+	Line int `json:"line,omitempty"`
+	Col  int `json:"col,omitempty"`
 }
 
 type ForthResponse struct {
@@ -27,23 +33,27 @@ type ForthResponse struct {
 // New structs for memory state serialization
 type MemoryState struct {
 	Objects []MemoryObject `json:"objects"`
+	Regions []world.Region `json:"regions,omitempty"`
 }
 
 type MemoryObject struct {
-	Type        string `json:"type"` // "hed" or "nod"
-	ID          string `json:"id"`
-	X           int    `json:"x"`
-	Y           int    `json:"y"`
-	Message     string `json:"message,omitempty"`
-	ConnectsToX *int   `json:"connectsToX"`         // Changed from connectsToX
-	ConnectsToY *int   `json:"connectsToY"`         // Changed from connectsToY
-	IsCurrent   bool   `json:"isCurrent,omitempty"` // Whether this nod is the current node for any head
+	Type        string   `json:"type"` // "hed" or "nod"
+	ID          string   `json:"id"`
+	X           int      `json:"x"`
+	Y           int      `json:"y"`
+	Message     string   `json:"message,omitempty"`
+	ConnectsToX *int     `json:"connectsToX"`         // Changed from connectsToX
+	ConnectsToY *int     `json:"connectsToY"`         // Changed from connectsToY
+	IsCurrent   bool     `json:"isCurrent,omitempty"` // Whether this nod is the current node for any head
+	Pending     []string `json:"pending,omitempty"`   // Launch actions waiting for the next boundary
+	CallsToX    *int     `json:"callsToX,omitempty"`  // First nod of the chain a subroutine nod plays
+	CallsToY    *int     `json:"callsToY,omitempty"`
+	Name        string   `json:"name,omitempty"` // Symbolic name given with hed-name or nod-name
 }
 
 var (
-	globalStack  forth.Stack
-	globalState  forth.State
-	globalMemory *world.Memory2D
+	globalSession *session
+	globalMemory  *world.Memory2D
 )
 
 var allowedOrigins = map[string]bool{
@@ -51,33 +61,6 @@ var allowedOrigins = map[string]bool{
 	"http://localhost:5174": true,
 }
 
-func initializeForth() {
-	// Initialize the world
-	rows, cols := 20, 20
-	clock := world.NewClock(100) // 100ms interval
-	globalMemory = world.NewMemory2D(rows, cols)
-
-	// Initialize Forth interpreter
-	globalStack = forth.CreateStack()
-	globalState = forth.CreateInitialState()
-
-	client := osc.NewClient("localhost", 7001)
-
-	// Import Dictionaries
-	hedDict := world.DefineHedDictionary(globalMemory)
-	worldDict := world.DefineWorldDictionary(globalMemory, clock, client)
-
-	// Merge dictionaries
-	for k, v := range hedDict {
-		worldDict[k] = v
-	}
-
-	for name, word := range worldDict {
-		globalState.Dictionary[name] = word
-	}
-	clock.Start(globalMemory)
-}
-
 // New function to extract coordinates from node ID
 func parseNodeID(id string) (x int, y int) {
 	fmt.Sscanf(id, "%d,%d", &x, &y)
@@ -92,6 +75,7 @@ func getMemoryState() MemoryState {
 	rows, cols := globalMemory.Dimensions()
 	state := MemoryState{
 		Objects: make([]MemoryObject, 0),
+		Regions: globalMemory.Regions(),
 	}
 
 	// Get current nodes for all heads
@@ -114,25 +98,34 @@ func getMemoryState() MemoryState {
 					Y:         y,
 					Message:   string(nod.Message()),
 					IsCurrent: currentNodes[nod.ID()],
+					Name:      nod.Name(),
 				}
 				if next := nod.Next(); next != nil {
 					nextX, nextY := parseNodeID(next.ID())
 					obj.ConnectsToX = intPtr(nextX)
 					obj.ConnectsToY = intPtr(nextY)
 				}
+				if first, _ := nod.Call(); first != nil {
+					callX, callY := parseNodeID(first.ID())
+					obj.CallsToX = intPtr(callX)
+					obj.CallsToY = intPtr(callY)
+				}
 				state.Objects = append(state.Objects, obj)
 			}
 		}
 	}
 
 	// Add all heads
+	pending := globalMemory.PendingLaunches()
 	for _, hed := range globalMemory.GetHeads() {
 		x, y := parseNodeID(hed.ID())
 		obj := MemoryObject{
-			Type: "hed",
-			ID:   hed.ID(),
-			X:    x,
-			Y:    y,
+			Type:    "hed",
+			ID:      hed.ID(),
+			X:       x,
+			Y:       y,
+			Pending: pending[hed.ID()],
+			Name:    hed.Name(),
 		}
 		if first := hed.FirstNod(); first != nil {
 			firstX, firstY := parseNodeID(first.ID())
@@ -238,6 +231,53 @@ func streamMessages(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// analyzeMemory reports problems in the link graph of the grid
+func analyzeMemory(w http.ResponseWriter, r *http.Request) {
+	enableCors(w, r)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(globalMemory.Analyze()); err != nil {
+		log.Printf("Error encoding analysis: %v", err)
+		http.Error(w, "Error encoding analysis", http.StatusInternalServerError)
+		return
+	}
+}
+
+// listWords documents every word in the dictionary for editor completion and
+// hover docs
+func listWords(w http.ResponseWriter, r *http.Request) {
+	enableCors(w, r)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	words := globalSession.Words()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(words); err != nil {
+		log.Printf("Error encoding words: %v", err)
+		http.Error(w, "Error encoding words", http.StatusInternalServerError)
+		return
+	}
+}
+
 func evaluateForth(w http.ResponseWriter, r *http.Request) {
 	enableCors(w, r)
 
@@ -258,12 +298,18 @@ func evaluateForth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Interpret the input
-	stack, state, output := forth.Interpret(req.Input, globalStack, globalState)
+	if req.Line < 1 {
+		req.Line = 1
+	}
+	if req.Col < 1 {
+		req.Col = 1
+	}
 
-	// Update global state
-	globalStack = stack
-	globalState = state
+	// Interpret the input, giving up if the client goes away
+	stack, output, err := globalSession.EvaluateAt(r.Context(), req.Input, req.Line, req.Col)
+	if err != nil {
+		output = append(output, "Error: "+err.Error())
+	}
 
 	// Prepare response
 	response := ForthResponse{
@@ -282,17 +328,66 @@ func evaluateForth(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func main() {
-	// Initialize Forth interpreter
-	initializeForth()
+// logReloads shows watched files being reloaded in the server log
+func logReloads() {
+	events, _ := connections.Subscribe()
+	for e := range events {
+		switch {
+		case e.Type == connections.EventReload:
+			log.Printf("Reloaded %s", e.File)
+		case e.Type == connections.EventError && e.File != "":
+			for _, line := range e.Lines {
+				log.Printf("Error reloading %s: %s", e.File, line)
+			}
+		}
+	}
+}
+
+// serve runs the HTTP server for the browser client along with the socket
+// REPL
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	port := fs.Int("port", 8080, "port for the HTTP server")
+	replAddr := fs.String("repl", "localhost:7070", "address for the socket REPL, a host:port or a unix socket path, empty to disable")
+	wf := addWorldFlags(fs)
+	fs.Parse(args)
+
+	host, oscPort, err := splitHostPort(wf.osc)
+	if err != nil {
+		return err
+	}
+
+	// Initialize the world
+	clock := world.NewClock(wf.tick)
+	memory, s, err := newWorld(wf.rows, wf.cols, clock, osc.NewClient(host, oscPort), wf.prelude, wf.roots)
+	if err != nil {
+		return err
+	}
+	globalMemory, globalSession = memory, s
+	clock.Start(globalMemory)
+	wf.startWatching(globalSession)
+	go logReloads()
+
+	if *replAddr != "" {
+		l, err := repl.Listen(*replAddr)
+		if err != nil {
+			return fmt.Errorf("starting REPL: %w", err)
+		}
+		fmt.Printf("REPL listening on %s\n", l.Addr())
+		go func() {
+			log.Printf("REPL stopped: %v", repl.Serve(l, globalSession))
+		}()
+	}
 
 	// Set up HTTP routes
 	http.HandleFunc("/evaluate", evaluateForth)
 	http.HandleFunc("/memory-stream", streamMemoryState)
 	http.HandleFunc("/message-stream", streamMessages)
+	http.HandleFunc("/analyze", analyzeMemory)
+	http.HandleFunc("/words", listWords)
 
 	// Start server
-	port := ":8080"
-	fmt.Printf("Starting Forth interpreter server on port %s\n", port)
-	log.Fatal(http.ListenAndServe(port, nil))
+	addr := fmt.Sprintf(":%d", *port)
+	fmt.Printf("Starting Forth interpreter server on port %s\n", addr)
+	return http.ListenAndServe(addr, nil)
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"3body/connections"
+	"3body/forth"
+)
+
+// fileVersion is what changes about a file when it is saved
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchFiles polls the files included in the session, including each one
+// again when it changes so its words are redefined in place. Heds pick up
+// the new definitions the next time they call them. What a reload prints is
+// published as a reload event and anything that goes wrong as an error
+// event, both with the file set.
+func watchFiles(s *session, every time.Duration) {
+	seen := make(map[string]fileVersion)
+	for range time.Tick(every) {
+		for _, path := range s.includedFiles() {
+			info, err := os.Stat(path)
+			if err != nil {
+				// Editors that save by renaming can leave the file missing
+				// for a moment, it is looked at again next time
+				continue
+			}
+
+			version := fileVersion{modTime: info.ModTime(), size: info.Size()}
+			last, ok := seen[path]
+			seen[path] = version
+			if !ok || last == version {
+				continue
+			}
+
+			output, err := s.reload(path)
+			event := connections.Event{Type: connections.EventReload, File: path, Lines: output}
+			var errors []string
+			for _, line := range output {
+				if forth.IsErrorLine(line) {
+					errors = append(errors, line)
+				}
+			}
+			if err != nil {
+				errors = append(errors, "Error: "+err.Error())
+			}
+			if len(errors) > 0 {
+				event = connections.Event{Type: connections.EventError, File: path, Lines: errors}
+			}
+			connections.Publish(event)
+		}
+	}
+}
+
+// includedFiles lists the files the session has included
+func (s *session) includedFiles() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.IncludedFiles()
+}
+
+// reload includes a file in the session again
+func (s *session) reload(path string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stack, state, output, err := forth.Reload(context.Background(), path, s.stack, s.state)
+	s.stack = stack
+	s.state = state
+	return output, err
+}
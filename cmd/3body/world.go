@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"3body/connections"
+	"3body/forth"
+	"3body/world"
+)
+
+// worldFlags are the settings shared by every command that runs a world
+type worldFlags struct {
+	rows, cols int
+	tick       int           // Clock interval in milliseconds
+	osc        string        // host:port osc messages are sent to
+	prelude    []string      // Forth files included before anything else runs
+	roots      []string      // Extra directories forth source may read files from
+	watch      time.Duration // How often included files are checked for changes, 0 for never
+}
+
+func addWorldFlags(fs *flag.FlagSet) *worldFlags {
+	f := &worldFlags{}
+	fs.IntVar(&f.rows, "rows", 20, "rows in the grid")
+	fs.IntVar(&f.cols, "cols", 20, "columns in the grid")
+	fs.IntVar(&f.tick, "tick", 100, "clock interval in milliseconds")
+	fs.StringVar(&f.osc, "osc", "localhost:7001", "host:port to send osc messages to")
+	addPreludeFlag(fs, &f.prelude)
+	addRootFlag(fs, &f.roots)
+	fs.DurationVar(&f.watch, "watch", 500*time.Millisecond, "how often to check included files for changes and reload them, 0 to not watch")
+	return f
+}
+
+func addPreludeFlag(fs *flag.FlagSet, prelude *[]string) {
+	fs.Func("prelude", "forth file to include at startup, may be repeated", func(path string) error {
+		*prelude = append(*prelude, path)
+		return nil
+	})
+}
+
+func addRootFlag(fs *flag.FlagSet, roots *[]string) {
+	fs.Func("root", "directory forth source may read files from besides the working directory and the prelude's, may be repeated", func(path string) error {
+		*roots = append(*roots, path)
+		return nil
+	})
+}
+
+// fileRoots lists the directories forth source may read files from: the
+// working directory, the directory of each prelude file and any extra ones
+func fileRoots(prelude, extra []string) ([]string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	roots := []string{wd}
+	for _, path := range prelude {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, filepath.Dir(abs))
+	}
+	for _, path := range extra {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, abs)
+	}
+	return roots, nil
+}
+
+// splitHostPort reads an osc host:port
+func splitHostPort(addr string) (string, int, error) {
+	host, portText, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, fmt.Errorf("bad osc address %q: %w", addr, err)
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return "", 0, fmt.Errorf("bad osc port %q", portText)
+	}
+	return host, port, nil
+}
+
+// session is a forth stack and state shared by everything evaluating in it
+type session struct {
+	mu    sync.Mutex
+	stack forth.Stack
+	state forth.State
+}
+
+// Evaluate runs source against the session's stack and state
+func (s *session) Evaluate(ctx context.Context, source string) (forth.Stack, []string, error) {
+	return s.EvaluateAt(ctx, source, 1, 1)
+}
+
+// EvaluateAt runs source taken from line and col of a larger source, so
+// errors point into the larger source
+func (s *session) EvaluateAt(ctx context.Context, source string, line, col int) (forth.Stack, []string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stack, state, output, err := forth.EvaluateAt(ctx, source, line, col, s.stack, s.state)
+	s.stack = stack
+	s.state = state
+	return stack, output, err
+}
+
+// Words documents every word in the session's dictionary
+func (s *session) Words() []forth.WordInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Words()
+}
+
+// newWorld creates a grid and a session whose dictionary acts on it, then
+// includes the prelude files in the session. Files can only be read from
+// the working directory, the prelude's directories and roots. The clock
+// isn't started.
+func newWorld(rows, cols int, clock *world.Clock, client world.OSCSender, prelude, roots []string) (*world.Memory2D, *session, error) {
+	memory := world.NewMemory2D(rows, cols)
+	state := forth.CreateInitialState()
+	var err error
+	if state.Roots, err = fileRoots(prelude, roots); err != nil {
+		return nil, nil, err
+	}
+
+	// Import Dictionaries
+	hedDict := world.DefineHedDictionary(memory)
+	worldDict := world.DefineWorldDictionary(memory, clock, client)
+	rhythmDict := world.DefineRhythmDictionary()
+	musicDict := world.DefineMusicDictionary(memory)
+	sceneDict := world.DefineSceneDictionary(memory)
+	eventDict := world.DefineEventDictionary(memory)
+	regionDict := world.DefineRegionDictionary(memory)
+	chainDict := world.DefineChainDictionary(memory)
+
+	// Merge dictionaries
+	for k, v := range hedDict {
+		worldDict[k] = v
+	}
+
+	for k, v := range rhythmDict {
+		worldDict[k] = v
+	}
+
+	for k, v := range musicDict {
+		worldDict[k] = v
+	}
+
+	for k, v := range sceneDict {
+		worldDict[k] = v
+	}
+
+	for k, v := range eventDict {
+		worldDict[k] = v
+	}
+
+	for k, v := range regionDict {
+		worldDict[k] = v
+	}
+
+	for k, v := range chainDict {
+		worldDict[k] = v
+	}
+
+	for name, word := range worldDict {
+		state.Dictionary[name] = word
+	}
+
+	docs, err := world.Docs()
+	if err != nil {
+		return nil, nil, err
+	}
+	state.AddDocs(docs)
+
+	s := &session{stack: forth.CreateStack(), state: state}
+	for _, path := range prelude {
+		if err := s.include(path); err != nil {
+			return nil, nil, err
+		}
+	}
+	return memory, s, nil
+}
+
+// include evaluates a file in the session. What the file prints goes to
+// stderr, the first error it reports is returned.
+func (s *session) include(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stack, state, output, err := forth.Include(context.Background(), path, s.stack, s.state)
+	s.stack = stack
+	s.state = state
+	if err != nil {
+		return fmt.Errorf("prelude %s: %w", path, err)
+	}
+	for _, line := range output {
+		if forth.IsErrorLine(line) {
+			return fmt.Errorf("prelude %s: %s", path, strings.TrimPrefix(line, "Error: "))
+		}
+		fmt.Fprintln(os.Stderr, line)
+	}
+	return nil
+}
+
+// startWatching reloads included files as they change if watching is on
+func (f *worldFlags) startWatching(s *session) {
+	if f.watch > 0 {
+		go watchFiles(s, f.watch)
+	}
+}
+
+// drainGraphics throws away graphics messages when there is no browser to
+// show them, otherwise m-lg and m-hg would wait for one forever. They are
+// still published as events.
+func drainGraphics() {
+	go func() {
+		for range connections.HTTPMessageChannel {
+		}
+	}()
+}
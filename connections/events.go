@@ -0,0 +1,88 @@
+package connections
+
+import "sync"
+
+// Kinds of Event
+const (
+	EventOutput  = "output"  // Lines printed by a nod's message
+	EventError   = "error"   // A hed or launch failing
+	EventMessage = "message" // A message sent out over osc or to the graphics
+	EventReload  = "reload"  // A watched source file being evaluated again after it changed
+)
+
+// Event is something that happened while the clock was running, such as a
+// nod's message printing output or a hed failing. Reload failures are
+// errors with File set.
+type Event struct {
+	Type    string   `json:"event"`
+	Hed     string   `json:"hed,omitempty"`
+	Nod     string   `json:"nod,omitempty"`
+	Tick    int      `json:"tick"`
+	Address string   `json:"address,omitempty"` // Where a message went, an osc address, "line" or "hydra"
+	File    string   `json:"file,omitempty"`    // Source file a reload came from
+	Lines   []string `json:"lines"`
+}
+
+// eventBuffer is how many events a slow subscriber can fall behind by before
+// it starts missing them
+const eventBuffer = 64
+
+// subscriber is a channel receiving events, lossless subscribers are waited
+// for rather than skipped when they fall behind
+type subscriber struct {
+	ch       chan Event
+	lossless bool
+}
+
+var (
+	subscribers   = map[chan Event]subscriber{}
+	subscribersMu sync.Mutex
+)
+
+// Subscribe returns a channel that receives every event published from now
+// on and a function that stops the subscription. Events are dropped if the
+// subscriber falls behind so it can never hold up the clock.
+func Subscribe() (<-chan Event, func()) {
+	return subscribe(false)
+}
+
+// SubscribeAll is like Subscribe but publishing waits for the subscriber
+// instead of dropping events, for headless runs where nothing is timing
+// critical. The subscriber must keep reading until it stops the subscription.
+func SubscribeAll() (<-chan Event, func()) {
+	return subscribe(true)
+}
+
+func subscribe(lossless bool) (<-chan Event, func()) {
+	ch := make(chan Event, eventBuffer)
+
+	subscribersMu.Lock()
+	subscribers[ch] = subscriber{ch: ch, lossless: lossless}
+	subscribersMu.Unlock()
+
+	return ch, func() {
+		subscribersMu.Lock()
+		defer subscribersMu.Unlock()
+		if _, ok := subscribers[ch]; ok {
+			delete(subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish sends an event to every subscriber
+func Publish(e Event) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+
+	for _, sub := range subscribers {
+		if sub.lossless {
+			sub.ch <- e
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package forth
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Problem is something wrong with forth source found without running it
+type Problem struct {
+	Line   int // 1-based line the problem starts on
+	Col    int // 1-based column the problem starts at, counted in characters
+	EndCol int // Column just past the end of the problem on the same line
+	Msg    string
+}
+
+// Check looks for unknown words and unbalanced [ ] { } and : ; in source
+// without running it. known reports whether a word is in the dictionary,
+// words defined in the source itself are known from where they are defined.
+// Words from vocabularies named with vocab or using are known without their
+// vocabulary, and a definition's locals are known inside it. What parsing
+// words read after them is skipped, including for words defined in the
+// source that use parse-name, parse-until or :.
+func Check(input string, known func(string) bool) []Problem {
+	tokens, err := Lex(input)
+	if lexErr, ok := err.(*LexError); ok {
+		return []Problem{{Line: lexErr.Line, Col: lexErr.Col, EndCol: lexErr.Col + 1, Msg: lexErr.Msg}}
+	}
+
+	defined := make(map[string]bool)
+	parsers := make(map[string]parseSpec)
+	for _, d := range sourceDefinitions(tokens) {
+		defined[d.name] = true
+		if spec, ok := definitionParses(d.definition); ok {
+			parsers[d.name] = spec
+		}
+	}
+
+	var problems []Problem
+	report := func(tok Token, format string, args ...interface{}) {
+		problems = append(problems, Problem{
+			Line:   tok.Line,
+			Col:    tok.Col,
+			EndCol: tok.Col + utf8.RuneCountInString(tok.Text),
+			Msg:    fmt.Sprintf(format, args...),
+		})
+	}
+
+	// open holds the brackets and : waiting to be closed, innermost last
+	var open []Token
+	closes := map[string]string{"]": "[", "}": "{", ";": ":"}
+	naming := false
+	var parsing string // The parsing word waiting for its names
+	var reading []bool // The names it has still to read, true for those : defines
+	var until string   // The word it reads up to once it has its names
+	var using []string
+	named := false // A definition's name has just been read, locals may follow
+	locals := make(map[string]bool)
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if naming {
+			naming = tok.Kind == TokenComment
+			named = !naming
+			continue
+		}
+		if named && tok.Kind != TokenComment {
+			named = false
+			texts := make([]string, 0, len(tokens)-i)
+			for _, t := range tokens[i:] {
+				texts = append(texts, t.Text)
+			}
+			if spec, n := parseLocals(texts); spec != nil {
+				for _, name := range spec.params {
+					locals[name] = true
+				}
+				i += n - 1
+				continue
+			}
+		}
+		if len(reading) > 0 && tok.Kind != TokenComment {
+			if reading[0] && tok.Kind == TokenWord {
+				defined[tok.Text] = true
+			}
+			if parsing == "vocab" || parsing == "using" {
+				using = append(using, tok.Text)
+			}
+			reading = reading[1:]
+			continue
+		}
+		if until != "" && tok.Kind != TokenComment {
+			if tok.Text == until && tok.Kind != TokenString {
+				until = ""
+			}
+			continue
+		}
+
+		switch tok.Kind {
+		case TokenComment, TokenString, TokenSigil, TokenNumber:
+			continue
+		}
+
+		switch tok.Text {
+		case "[", "{", ":":
+			if tok.Text == ":" {
+				// Inside a definition : is compiled like any other word, for
+				// defining words that start definitions of their own
+				if inDefinition(open) {
+					continue
+				}
+				naming = true
+				locals = make(map[string]bool)
+			}
+			open = append(open, tok)
+			continue
+		case "]", "}", ";":
+			want := closes[tok.Text]
+			if len(open) == 0 || open[len(open)-1].Text != want {
+				if len(open) == 0 {
+					report(tok, "%s without a matching %s", tok.Text, want)
+				} else {
+					last := open[len(open)-1]
+					report(tok, "%s closes the %s at %s", tok.Text, last.Text, last.Position())
+				}
+				// Drop back to the matching opener if there is one
+				for i := len(open) - 1; i >= 0; i-- {
+					if open[i].Text == want {
+						open = open[:i]
+						break
+					}
+				}
+				continue
+			}
+			open = open[:len(open)-1]
+			if want == ":" {
+				locals = make(map[string]bool)
+			}
+			continue
+		}
+
+		spec, ok := parsers[tok.Text]
+		if !defined[tok.Text] {
+			spec, ok = parsingWords[tok.Text]
+		}
+		if ok {
+			parsing, reading, until = tok.Text, spec.names, spec.until
+			// Compiled into a definition the word reads its names from where
+			// it is written when it runs, but the definition still ends at ;
+			if inDefinition(open) {
+				until = ""
+			}
+			continue
+		}
+		if known(tok.Text) || defined[tok.Text] || locals[tok.Text] || knownInVocab(tok.Text, using, known) {
+			continue
+		}
+		if _, err := strconv.ParseFloat(tok.Text, 64); err == nil {
+			continue
+		}
+		if _, ok := ParseNoteName(tok.Text); ok {
+			continue
+		}
+		report(tok, "unknown word %s", tok.Text)
+	}
+
+	for _, tok := range open {
+		closer := map[string]string{"[": "]", "{": "}", ":": ";"}[tok.Text]
+		report(tok, "%s is never closed with %s", tok.Text, closer)
+	}
+	return problems
+}
+
+// inDefinition reports whether a : is open
+func inDefinition(open []Token) bool {
+	for _, o := range open {
+		if o.Text == ":" {
+			return true
+		}
+	}
+	return false
+}
+
+// knownInVocab reports whether word is known in one of the vocabularies
+func knownInVocab(word string, using []string, known func(string) bool) bool {
+	for _, vocab := range using {
+		if known(Qualify(vocab, word)) {
+			return true
+		}
+	}
+	return false
+}
+
+// DocumentSource documents the words defined with : in source without
+// running it
+func DocumentSource(input string) []WordInfo {
+	tokens, _ := Lex(input)
+
+	var words []WordInfo
+	for _, d := range sourceDefinitions(tokens) {
+		definition := make([]string, len(d.definition))
+		for i, tok := range d.definition {
+			definition[i] = tok.Text
+		}
+		words = append(words, documentDefinition(d.name, definition))
+	}
+	return words
+}
+
+// sourceDefinition is a word defined with : in source
+type sourceDefinition struct {
+	name       string
+	definition []Token // The tokens between the name and the ;
+}
+
+// sourceDefinitions finds the words defined with : in tokens
+func sourceDefinitions(tokens []Token) []sourceDefinition {
+	var defs []sourceDefinition
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].Text != ":" || tokens[i].Kind != TokenWord || i+1 >= len(tokens) {
+			continue
+		}
+
+		def := sourceDefinition{name: tokens[i+1].Text}
+		j := i + 2
+		for ; j < len(tokens) && !(tokens[j].Text == ";" && tokens[j].Kind == TokenWord); j++ {
+			def.definition = append(def.definition, tokens[j])
+			// postpone ; compiles the ; rather than ending the definition
+			if tokens[j].Text == "postpone" && j+1 < len(tokens) {
+				j++
+				def.definition = append(def.definition, tokens[j])
+			}
+		}
+		defs = append(defs, def)
+		i = j
+	}
+	return defs
+}
@@ -1,10 +1,12 @@
 package forth
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // CreateStack initializes an empty stack
@@ -14,13 +16,23 @@ func CreateStack() Stack {
 
 // CreateInitialState initializes interpreter state
 func CreateInitialState() State {
+	docs := make(WordDocs)
+	for name, info := range coreDocs() {
+		docs[name] = info
+	}
+
 	return State{
 		Dictionary:        createInitialDictionary(),
+		Docs:              docs,
+		Immediate:         map[string]bool{";": true, "[[": true, "postpone": true, "literal": true},
 		Compiling:         false,
-		CollectingBlock:   false,
 		CurrentDefinition: make([]string, 0),
 		CurrentWord:       nil,
 		Globals:           make(map[string]StackItem),
+		Included:          make(map[string]bool),
+		Key:               60,
+		Scale:             "major",
+		Limits:            DefaultLimits,
 	}
 }
 
@@ -50,7 +62,7 @@ func formatStackItem(val interface{}) string {
 		depth := 0
 		var currentBlock strings.Builder
 
-		for _, token := range v.tokens {
+		for _, token := range v.captured() {
 			if token == "{" {
 				if depth > 0 {
 					currentBlock.WriteString(token + " ")
@@ -84,7 +96,8 @@ func formatStackItem(val interface{}) string {
 
 // createInitialDictionary creates the basic Forth dictionary
 func createInitialDictionary() Dictionary {
-	return Dictionary{
+	dict := Dictionary{
+		// + ( a b -- sum ) adds the top two numbers
 		"+": func(stack Stack, state State) (Stack, State, []string) {
 			if len(stack) < 2 {
 				return stack, state, []string{"stack underflow"}
@@ -93,6 +106,7 @@ func createInitialDictionary() Dictionary {
 			s1, a, _ := Pop(s)
 			return Push(s1, a.(float64)+b.(float64)), state, nil
 		},
+		// - ( a b -- difference ) subtracts b from a
 		"-": func(stack Stack, state State) (Stack, State, []string) {
 			if len(stack) < 2 {
 				return stack, state, []string{"stack underflow"}
@@ -101,41 +115,87 @@ func createInitialDictionary() Dictionary {
 			s1, a, _ := Pop(s)
 			return Push(s1, a.(float64)-b.(float64)), state, nil
 		},
-		// Add other basic operations here
+		// : ( -- ) starts a definition, the next word is its name. A stack
+		// comment straight after the name documents the new word, a
+		// { pitch vel -- } after that gives it locals. Inside a vocab the word
+		// is named vocab:name.
 		":": func(stack Stack, state State) (Stack, State, []string) {
 			if state.Compiling {
-				return stack, state, []string{"nested definitions not allowed"}
+				return stack, state, []string{"Error: nested definitions not allowed"}
 			}
+			tok, errs := parseName(":", state.input)
+			if errs != nil {
+				return stack, state, errs
+			}
+
+			wordName := tok.Text
 			newState := state
 			newState.Compiling = true
+			newState.CurrentWord = &wordName
+			newState.CurrentDefinition = make([]string, 0)
+			newState.compileFrom = state.input
 			return stack, newState, nil
 		},
+		// ; ( -- ) ends a definition and adds the word to the dictionary. It
+		// is immediate, so it runs rather than being compiled.
 		";": func(stack Stack, state State) (Stack, State, []string) {
 			if !state.Compiling {
-				return stack, state, []string{"not in compilation mode"}
+				return stack, state, []string{"Error: not in compilation mode"}
 			}
 			if state.CurrentWord == nil {
-				return stack, state, []string{"no word name provided"}
+				return stack, state, []string{"Error: no word name provided"}
 			}
 
 			// Create new word from current definition
-			wordName := *state.CurrentWord
+			wordName := Qualify(state.Vocab, *state.CurrentWord)
 			definition := state.CurrentDefinition
 
+			// The word finds the words it calls through the vocabularies in
+			// use where it was defined, not where it is called from. Parsing
+			// words in it read from the source calling it. Each call gets
+			// its own locals.
+			using := state.Using
+			locals, body := splitLocals(definition)
+			source := strings.Join(body, " ")
 			state.Dictionary[wordName] = func(s Stack, st State) (Stack, State, []string) {
-				return Interpret(strings.Join(definition, " "), s, st)
+				var frame map[string]StackItem
+				if locals != nil {
+					var err error
+					if s, frame, err = locals.bindLocals(wordName, s); err != nil {
+						return s, st, []string{err.Error()}
+					}
+				}
+
+				callerUsing, callerInput, callerLocals := st.Using, st.input, st.locals
+				st.Using, st.locals = using, frame
+				st.input, st.parseCaller = st.tokens, true
+				s, st, output := Interpret(source, s, st)
+				st.Using, st.input, st.locals = callerUsing, callerInput, callerLocals
+				return s, st, output
+			}
+			delete(state.Immediate, wordName)
+			if state.Docs != nil {
+				info := documentDefinition(wordName, definition)
+				if state.Vocab != "" {
+					info.Category = state.Vocab
+				}
+				state.Docs[wordName] = info
 			}
 
 			newState := state
 			newState.Compiling = false
 			newState.CurrentDefinition = nil
 			newState.CurrentWord = nil
+			newState.compileFrom = nil
+			newState.lastWord = wordName
 
 			return stack, newState, nil
 		},
+		// [ ( -- "[" ) starts an array
 		"[": func(stack Stack, state State) (Stack, State, []string) {
 			return Push(stack, "["), state, nil
 		},
+		// ] ( "[" items -- arr ) collects everything since [ into an array
 		"]": func(stack Stack, state State) (Stack, State, []string) {
 			arr, newStack, err := GetArray(stack)
 			if err != nil {
@@ -144,6 +204,7 @@ func createInitialDictionary() Dictionary {
 
 			return Push(newStack, arr), state, nil
 		},
+		// print-array ( arr -- ) prints an array
 		"print-array": func(stack Stack, state State) (Stack, State, []string) {
 			if len(stack) < 1 {
 				return stack, state, []string{"stack underflow"}
@@ -154,7 +215,7 @@ func createInitialDictionary() Dictionary {
 			// Try to convert item to array
 			arr, ok := item.([]interface{})
 			if !ok {
-				return stack, state, []string{"top item is not an array"}
+				return stack, state, []string{"Error: top item is not an array"}
 			}
 
 			// Build string representation of array
@@ -172,27 +233,38 @@ func createInitialDictionary() Dictionary {
 
 			return s, state, []string{fmt.Sprintf("[ %s ]", strings.Join(elements, " "))}
 		},
+		// { ( -- block ) reads the words up to the matching } and pushes
+		// them as a quoted block rather than running them. Locals in the
+		// block are those of the word it is made in. exec sees and sets
+		// their current values, and the block's source, such as in a nod
+		// message, has them replaced by their values.
 		"{": func(stack Stack, state State) (Stack, State, []string) {
-			newState := state
-			newState.CollectingBlock = true
-			newState.CurrentDefinition = make([]string, 0)
-			return stack, newState, nil
+			tokens := make([]string, 0)
+			depth := 0
+			for {
+				tok, ok := state.tokens.nextWord()
+				if !ok {
+					return stack, state, []string{"Error: { is never closed with }"}
+				}
+				if tok.Kind == TokenBracket {
+					switch tok.Text {
+					case "{":
+						depth++
+					case "}":
+						if depth == 0 {
+							return Push(stack, QuotedBlock{tokens: tokens, locals: state.locals}), state, nil
+						}
+						depth--
+					}
+				}
+				tokens = append(tokens, tok.Text)
+			}
 		},
+		// } ( -- ) ends a quoted block, { reads up to it
 		"}": func(stack Stack, state State) (Stack, State, []string) {
-			if !state.CollectingBlock {
-				return stack, state, []string{"not in quoted block mode"}
-			}
-
-			block := QuotedBlock{
-				tokens: state.CurrentDefinition,
-			}
-
-			newState := state
-			newState.CollectingBlock = false
-			newState.CurrentDefinition = nil
-
-			return Push(stack, block), newState, nil
+			return stack, state, []string{"Error: } without a matching {"}
 		},
+		// exec ( block -- ) runs a quoted block
 		"exec": func(stack Stack, state State) (Stack, State, []string) {
 			if len(stack) < 1 {
 				return stack, state, []string{"stack underflow"}
@@ -201,11 +273,17 @@ func createInitialDictionary() Dictionary {
 			s, item, _ := Pop(stack)
 			block, ok := item.(QuotedBlock)
 			if !ok {
-				return stack, state, []string{"top item is not a quoted block"}
+				return stack, state, []string{"Error: top item is not a quoted block"}
 			}
 
-			return Interpret(strings.Join(block.tokens, " "), s, state)
+			// The block runs with the locals of the word it was made in
+			callerLocals := state.locals
+			state.locals = block.locals
+			s, state, output := Interpret(strings.Join(block.tokens, " "), s, state)
+			state.locals = callerLocals
+			return s, state, output
 		},
+		// backtick ( block -- block ) wraps each word of a block in backticks
 		"backtick": func(stack Stack, state State) (Stack, State, []string) {
 			if len(stack) < 1 {
 				return stack, state, []string{"stack underflow"}
@@ -214,16 +292,69 @@ func createInitialDictionary() Dictionary {
 			s, item, _ := Pop(stack)
 			block, ok := item.(QuotedBlock)
 			if !ok {
-				return stack, state, []string{"top item is not a quoted block"}
+				return stack, state, []string{"Error: top item is not a quoted block"}
 			}
 
-			wrappedTokens := make([]string, len(block.tokens))
-			for i, token := range block.tokens {
+			tokens := block.captured()
+			wrappedTokens := make([]string, len(tokens))
+			for i, token := range tokens {
 				wrappedTokens[i] = "`" + token + "`"
 			}
 
 			return Push(s, QuotedBlock{tokens: wrappedTokens}), state, nil
 		},
+		// sigil ( 'name { block } -- ) registers a sigil defined in forth. The
+		// block receives the sigil value and its result replaces the sigil.
+		"sigil": func(stack Stack, state State) (Stack, State, []string) {
+			if len(stack) < 2 {
+				return stack, state, []string{"stack underflow"}
+			}
+
+			s, item, _ := Pop(stack)
+			block, ok := item.(QuotedBlock)
+			if !ok {
+				return stack, state, []string{"Error: top item is not a quoted block"}
+			}
+
+			name, s, err := PopString(s)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			source := block.Source()
+			definedIn := state
+			err = RegisterSigil(name, func(value string, call *SigilCall) (string, error) {
+				var arg StackItem = value
+				if num, err := strconv.ParseFloat(value, 64); err == nil {
+					arg = num
+				}
+
+				sigilState := definedIn
+				sigilState.Context = call.Context
+				result, _, output := Interpret(source, Stack{arg}, sigilState)
+				if len(output) > 0 {
+					return "", fmt.Errorf("%s", strings.Join(output, " "))
+				}
+				if len(result) == 0 {
+					return "", fmt.Errorf("sigil %s left nothing on the stack", name)
+				}
+
+				switch v := result[len(result)-1].(type) {
+				case float64:
+					return strconv.FormatFloat(v, 'g', -1, 64), nil
+				case string:
+					return v, nil
+				default:
+					return formatStackItem(v), nil
+				}
+			})
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return s, state, nil
+		},
+		// set ( 'name value -- ) stores a value in a global variable
 		"set": func(stack Stack, state State) (Stack, State, []string) {
 			if len(stack) < 2 {
 				return stack, state, []string{"stack underflow"}
@@ -234,7 +365,7 @@ func createInitialDictionary() Dictionary {
 
 			name, ok := nameItem.(string)
 			if !ok {
-				return stack, state, []string{"name must be a string"}
+				return stack, state, []string{"Error: name must be a string"}
 			}
 
 			newState := state
@@ -242,6 +373,7 @@ func createInitialDictionary() Dictionary {
 
 			return s, newState, nil
 		},
+		// get ( 'name -- value ) pushes the value of a global variable
 		"get": func(stack Stack, state State) (Stack, State, []string) {
 			if len(stack) < 1 {
 				return stack, state, []string{"stack underflow"}
@@ -251,16 +383,55 @@ func createInitialDictionary() Dictionary {
 
 			name, ok := nameItem.(string)
 			if !ok {
-				return stack, state, []string{"name must be a string"}
+				return stack, state, []string{"Error: name must be a string"}
 			}
 
 			value, exists := state.Globals[name]
 			if !exists {
-				return stack, state, []string{"undefined variable: " + name}
+				return stack, state, []string{"Error: undefined variable: " + name}
 			}
 
 			return Push(s, value), state, nil
 		},
+		// set-limits ( steps depth stack ms -- ) bounds each evaluation, 0
+		// turns a limit off. Heds take the limits in force when they are made.
+		"set-limits": func(stack Stack, state State) (Stack, State, []string) {
+			if len(stack) < 4 {
+				return stack, state, []string{"stack underflow"}
+			}
+
+			values := make([]int, 4)
+			s := stack
+			for i := 3; i >= 0; i-- {
+				v, rest, err := PopInt(s)
+				if err != nil {
+					return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+				}
+				if v < 0 {
+					return stack, state, []string{"Error: limits can't be negative"}
+				}
+				values[i], s = v, rest
+			}
+
+			newState := state
+			newState.Limits = Limits{
+				MaxSteps: values[0],
+				MaxDepth: values[1],
+				MaxStack: values[2],
+				Timeout:  time.Duration(values[3]) * time.Millisecond,
+			}
+			return s, newState, nil
+		},
+		// limits ( -- steps depth stack ms ) pushes the evaluation limits
+		"limits": func(stack Stack, state State) (Stack, State, []string) {
+			l := state.Limits
+			stack = Push(stack, float64(l.MaxSteps))
+			stack = Push(stack, float64(l.MaxDepth))
+			stack = Push(stack, float64(l.MaxStack))
+			stack = Push(stack, float64(l.Timeout.Milliseconds()))
+			return stack, state, nil
+		},
+		// print-stack ( -- ) prints the stack, top first
 		"print-stack": func(stack Stack, state State) (Stack, State, []string) {
 			if len(stack) == 0 {
 				return stack, state, []string{"<empty stack>"}
@@ -273,6 +444,7 @@ func createInitialDictionary() Dictionary {
 
 			return stack, state, []string{strings.Join(elements, "\n")}
 		},
+		// . ( x -- ) prints and drops the top item
 		".": func(stack Stack, state State) (Stack, State, []string) {
 			// Check for stack underflow
 			if len(stack) < 1 {
@@ -289,147 +461,161 @@ func createInitialDictionary() Dictionary {
 			return newStack, state, []string{formatStackItem(item)}
 		},
 	}
-}
-
-// splitPreservingStrings splits input while preserving quoted strings
-func splitPreservingStrings(input string) []string {
-	var tokens []string
-	var currentToken strings.Builder
-	inBacktick := false
-	inQuote := false
-
-	// First, normalize all whitespace characters to spaces except when in quotes
-	normalized := make([]rune, 0, len(input))
-	tempInQuote := false
-	tempInBacktick := false
 
-	for _, char := range input {
-		switch {
-		case char == '`':
-			tempInBacktick = !tempInBacktick
-			normalized = append(normalized, char)
-		case char == '"':
-			tempInQuote = !tempInQuote
-			normalized = append(normalized, char)
-		case (char == '\n' || char == '\r' || char == '\t') && !tempInQuote && !tempInBacktick:
-			normalized = append(normalized, ' ')
-		default:
-			normalized = append(normalized, char)
-		}
+	for name, word := range introspectionDictionary() {
+		dict[name] = word
 	}
+	for name, word := range includeDictionary() {
+		dict[name] = word
+	}
+	for name, word := range compileDictionary() {
+		dict[name] = word
+	}
+	for name, word := range localsDictionary() {
+		dict[name] = word
+	}
+	return dict
+}
 
-	input = string(normalized)
-
-	for _, char := range input {
-		switch {
-		case char == '`' && !inQuote:
-			if inBacktick {
-				currentToken.WriteRune(char)
-				tokens = append(tokens, currentToken.String())
-				currentToken.Reset()
-				inBacktick = false
-			} else {
-				if currentToken.Len() > 0 {
-					tokens = append(tokens, currentToken.String())
-					currentToken.Reset()
-				}
-				currentToken.WriteRune(char)
-				inBacktick = true
-			}
-		case char == '"' && !inBacktick:
-			if inQuote {
-				currentToken.WriteRune(char)
-				tokens = append(tokens, currentToken.String())
-				currentToken.Reset()
-				inQuote = false
-			} else {
-				if currentToken.Len() > 0 {
-					tokens = append(tokens, currentToken.String())
-					currentToken.Reset()
-				}
-				currentToken.WriteRune(char)
-				inQuote = true
-			}
-		case char == ' ' && !inBacktick && !inQuote:
-			if currentToken.Len() > 0 {
-				tokens = append(tokens, currentToken.String())
-				currentToken.Reset()
-			}
-		default:
-			currentToken.WriteRune(char)
+// Interpret processes a Forth string and returns the new stack and state.
+// Called from a word it shares the limits of the evaluation that called the
+// word, otherwise it starts a new evaluation with the state's limits.
+func Interpret(input string, stack Stack, state State) (Stack, State, []string) {
+	if state.budget == nil || state.budget.done.Load() {
+		newStack, newState, output, err := Evaluate(context.Background(), input, stack, state)
+		if err != nil {
+			output = append(output, "Error: "+err.Error())
 		}
+		return newStack, newState, output
 	}
+	return interpret(input, 1, 1, stack, state)
+}
 
-	if currentToken.Len() > 0 {
-		tokens = append(tokens, currentToken.String())
+func interpret(input string, line, col int, stack Stack, state State) (Stack, State, []string) {
+	tokens, err := LexAt(input, line, col)
+	if err != nil {
+		return stack, state, []string{"Error: " + err.Error()}
 	}
 
-	return tokens
-}
-
-// Interpret processes a Forth string and returns the new stack and state
-func Interpret(input string, stack Stack, state State) (Stack, State, []string) {
-	words := splitPreservingStrings(input)
+	// Positions only mean something in the source the user wrote, so errors
+	// from words called by other words are located at the outermost call
+	depth := state.depth
+	b := state.budget
 	currentStack := stack
 	currentState := state
 	var output []string
-	blockDepth := 0
 
-	for _, word := range words {
-		if currentState.Compiling && !currentState.CollectingBlock {
-			if currentState.CurrentWord == nil {
-				wordName := word
-				currentState.CurrentWord = &wordName
-				continue
-			}
-			if word != ";" {
-				currentState.CurrentDefinition = append(currentState.CurrentDefinition, word)
-				continue
-			}
-		}
+	// Parsing words read from this source unless it is the body of a word,
+	// then they read from the source that called the word
+	stream := &tokenStream{tokens: tokens}
+	defer func() { stream.done = true }()
+	source := stream
+	if state.parseCaller {
+		source = state.input
+	}
+	currentState.tokens, currentState.input, currentState.parseCaller = stream, source, false
 
-		if currentState.CollectingBlock {
-			if word == "{" {
-				blockDepth++
-			} else if word == "}" {
-				if blockDepth == 0 {
-					block := QuotedBlock{
-						tokens: currentState.CurrentDefinition,
-					}
+	// A definition left open by an earlier evaluation carries on here
+	if depth == 0 && currentState.Compiling && (currentState.compileFrom == nil || currentState.compileFrom.done) {
+		currentState.compileFrom = stream
+	}
 
-					newState := state
-					newState.CollectingBlock = false
-					newState.CurrentDefinition = nil
+	for {
+		tok, ok := stream.next()
+		if !ok {
+			break
+		}
+		compiling := currentState.Compiling && currentState.compileFrom == stream
 
-					currentStack = Push(currentStack, block)
-					currentState = newState
-					continue
-				}
-				blockDepth--
+		// Comments are only kept in definitions, where they document the word
+		if tok.Kind == TokenComment && !compiling {
+			continue
+		}
+
+		if err := b.step(depth, len(currentStack)); err != nil {
+			if depth == 0 && err.Position == "" {
+				err.Position = tok.Position()
 			}
+			return currentStack, currentState, output
+		}
+
+		word := tok.Text
+		immediate := (tok.Kind == TokenWord || tok.Kind == TokenBracket) && currentState.isImmediate(word)
+		if compiling && !immediate {
 			currentState.CurrentDefinition = append(currentState.CurrentDefinition, word)
 			continue
 		}
 
+		switch {
 		// sigilnotation
 		// these are replaced in place when the nod is evaluated
-		if strings.HasPrefix(word, "$") {
+		case tok.Kind == TokenSigil:
 			currentStack = Push(currentStack, word)
-		} else if dictWord, exists := currentState.Dictionary[word]; exists {
-			var newOutput []string
-			currentStack, currentState, newOutput = dictWord(currentStack, currentState)
-			output = append(output, newOutput...)
-		} else if num, err := strconv.ParseFloat(word, 64); err == nil {
-			currentStack = Push(currentStack, num)
-		} else if (strings.HasPrefix(word, "\"") && strings.HasSuffix(word, "\"")) ||
-			(strings.HasPrefix(word, "`") && strings.HasSuffix(word, "`")) {
-			currentStack = Push(currentStack, word[1:len(word)-1])
-		} else if strings.HasPrefix(word, "'") {
-			currentStack = Push(currentStack, word[1:])
-		} else {
-			return currentStack, currentState, append(output, "Unknown word: "+word)
+		case tok.Kind == TokenString:
+			currentStack = Push(currentStack, tok.Value)
+		case tok.Kind == TokenWord && currentState.locals[word] != nil:
+			currentStack = Push(currentStack, currentState.locals[word])
+		default:
+			if dictWord, exists := currentState.lookup(word); exists {
+				var newOutput []string
+				currentState.depth = depth + 1
+				currentStack, currentState, newOutput = dictWord(currentStack, currentState)
+				currentState.depth = depth
+				currentState.budget = b
+				currentState.tokens, currentState.input = stream, source
+				if depth == 0 {
+					newOutput = locateErrors(newOutput, tok)
+				}
+				output = append(output, newOutput...)
+
+				// A limit hit inside the word stops everything
+				if err := b.err; err != nil {
+					if depth == 0 && err.Position == "" {
+						err.Position = tok.Position()
+					}
+					return currentStack, currentState, output
+				}
+			} else if num, err := strconv.ParseFloat(word, 64); err == nil {
+				currentStack = Push(currentStack, num)
+			} else if note, ok := ParseNoteName(word); ok {
+				currentStack = Push(currentStack, note)
+			} else {
+				unknown := []string{"Unknown word: " + word}
+				if depth == 0 {
+					unknown = locateErrors(unknown, tok)
+				}
+				return currentStack, currentState, append(output, unknown...)
+			}
 		}
 	}
 
+	if err := b.checkStack(len(currentStack)); err != nil && depth == 0 && err.Position == "" {
+		err.Position = "at the end of the input"
+	}
+
 	return currentStack, currentState, output
 }
+
+// errorPrefixes are how words start the output lines that report errors
+var errorPrefixes = []string{"Error", "error", "Unknown word", "stack underflow"}
+
+// IsErrorLine reports whether a line of output reports an error
+func IsErrorLine(line string) bool {
+	for _, prefix := range errorPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// locateErrors adds the position of the token that was running to any
+// error lines in its output
+func locateErrors(output []string, tok Token) []string {
+	for i, line := range output {
+		if IsErrorLine(line) {
+			output[i] = fmt.Sprintf("%s (%s)", line, tok.Position())
+		}
+	}
+	return output
+}
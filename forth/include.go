@@ -0,0 +1,266 @@
+package forth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// vocabSeparator joins a vocabulary and a word into the name the word is
+// kept under in the dictionary, as in drums:kick
+const vocabSeparator = ":"
+
+// Qualify returns the name a word defined in vocab is kept under
+func Qualify(vocab, word string) string {
+	if vocab == "" {
+		return word
+	}
+	return vocab + vocabSeparator + word
+}
+
+// Resolve finds the dictionary name of a word, looking through the
+// vocabularies in use before the words defined outside of any
+func (s State) Resolve(word string) (string, bool) {
+	for _, vocab := range s.Using {
+		if _, ok := s.Dictionary[Qualify(vocab, word)]; ok {
+			return Qualify(vocab, word), true
+		}
+	}
+	_, ok := s.Dictionary[word]
+	return word, ok
+}
+
+// lookup returns the dictionary word a name resolves to
+func (s State) lookup(word string) (DictionaryWord, bool) {
+	name, ok := s.Resolve(word)
+	if !ok {
+		return nil, false
+	}
+	return s.Dictionary[name], true
+}
+
+// useVocab puts vocab at the front of the search order. Using is copied so
+// states that were copied from this one keep their own order.
+func (s State) useVocab(vocab string) State {
+	using := []string{vocab}
+	for _, v := range s.Using {
+		if v != vocab {
+			using = append(using, v)
+		}
+	}
+	s.Using = using
+	return s
+}
+
+// vocabExists reports whether any word has been defined in vocab
+func (s State) vocabExists(vocab string) bool {
+	prefix := vocab + vocabSeparator
+	for name := range s.Dictionary {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Include evaluates a forth file as a prelude, before anything else runs.
+// Unlike the include word the vocab and using in the file stay in force
+// afterwards, so a prelude can set up the words everything else sees.
+func Include(ctx context.Context, path string, stack Stack, state State) (Stack, State, []string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return stack, state, nil, err
+	}
+	src, err := os.ReadFile(abs)
+	if err != nil {
+		return stack, state, nil, err
+	}
+	if state.Included != nil {
+		state.Included[abs] = true
+	}
+
+	dir := state.Dir
+	state.Dir = filepath.Dir(abs)
+	newStack, newState, output, err := Evaluate(ctx, string(src), stack, state)
+	newState.Dir = dir
+	return newStack, newState, output, err
+}
+
+// Reload evaluates a file that has changed as the include word does, even
+// though it has been included before. Words it defines replace the old ones
+// in the shared dictionary, so heds calling them change too.
+func Reload(ctx context.Context, path string, stack Stack, state State) (Stack, State, []string, error) {
+	return evaluate(ctx, stack, state, func(stack Stack, state State) (Stack, State, []string) {
+		return includeFile(path, true, stack, state)
+	})
+}
+
+// IncludedFiles lists the absolute paths of the files that have been
+// included, sorted
+func (s State) IncludedFiles() []string {
+	files := make([]string, 0, len(s.Included))
+	for path := range s.Included {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// ResolvePath returns the absolute path of a file named in forth source.
+// Relative paths are found from the directory of the file being included.
+// Paths outside the roots are refused, following symlinks, so source sent
+// to a server can only reach the files it was started with.
+func (s State) ResolvePath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.Dir, path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if len(s.Roots) == 0 {
+		return abs, nil
+	}
+
+	// A file that doesn't exist yet is checked by the directory it would
+	// be made in
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		dir, dirErr := filepath.EvalSymlinks(filepath.Dir(abs))
+		if dirErr != nil {
+			dir = filepath.Dir(abs)
+		}
+		real = filepath.Join(dir, filepath.Base(abs))
+	}
+	for _, root := range s.Roots {
+		realRoot, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			realRoot = root
+		}
+		if within(root, abs) && within(realRoot, real) {
+			return abs, nil
+		}
+	}
+	return "", fmt.Errorf("%s is outside the directories forth can use files in", abs)
+}
+
+// within reports whether path is dir or inside it
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// includeFile evaluates a file, only once unless again is set. Relative
+// paths are found from the directory of the file doing the including. The
+// file starts with no vocabularies in use and whatever vocab and using it
+// does ends with it.
+func includeFile(path string, again bool, stack Stack, state State) (Stack, State, []string) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(state.Dir, path)
+	}
+	abs, err := state.ResolvePath(path)
+	if err != nil {
+		return stack, state, []string{fmt.Sprintf("Error: including %s: %v", path, err)}
+	}
+	if !again && state.Included != nil && state.Included[abs] {
+		return stack, state, nil
+	}
+
+	src, err := os.ReadFile(abs)
+	if err != nil {
+		return stack, state, []string{fmt.Sprintf("Error: including %s: %v", path, err)}
+	}
+	if state.Included != nil {
+		state.Included[abs] = true
+	}
+
+	fileState := state
+	fileState.Dir = filepath.Dir(abs)
+	fileState.Vocab = ""
+	fileState.Using = nil
+	// Starting at the top level locates errors within the file
+	fileState.depth = 0
+	newStack, newState, output := Interpret(string(src), stack, fileState)
+
+	for i, line := range output {
+		if IsErrorLine(line) {
+			output[i] = fmt.Sprintf("%s in %s", line, path)
+		}
+	}
+
+	newState.Dir = state.Dir
+	newState.Vocab = state.Vocab
+	newState.Using = state.Using
+	newState.depth = state.depth
+	return newStack, newState, output
+}
+
+// includeDictionary creates the words for loading files and organising
+// words into vocabularies
+func includeDictionary() Dictionary {
+	return Dictionary{
+		// include ( -- ) evaluates the file named after it, as in
+		// include "lib/drums.fs". Files are only included once.
+		"include": func(stack Stack, state State) (Stack, State, []string) {
+			tok, errs := parseName("include", state.tokens)
+			if errs != nil {
+				return stack, state, errs
+			}
+			return includeFile(tok.Value, false, stack, state)
+		},
+
+		// vocab ( -- ) puts the words defined after it in the vocabulary named
+		// after it, as in vocab drums. They can be called as drums:word from
+		// anywhere.
+		"vocab": func(stack Stack, state State) (Stack, State, []string) {
+			tok, errs := parseName("vocab", state.tokens)
+			if errs != nil {
+				return stack, state, errs
+			}
+			if tok.Kind != TokenWord || strings.Contains(tok.Text, vocabSeparator) {
+				return stack, state, []string{fmt.Sprintf("Error: bad vocabulary name %s", tok.Text)}
+			}
+			newState := state.useVocab(tok.Text)
+			newState.Vocab = tok.Text
+			return stack, newState, nil
+		},
+
+		// end-vocab ( -- ) goes back to defining words outside of any
+		// vocabulary. The vocabulary stays in use.
+		"end-vocab": func(stack Stack, state State) (Stack, State, []string) {
+			newState := state
+			newState.Vocab = ""
+			return stack, newState, nil
+		},
+
+		// using ( -- ) lets the words of the vocabulary named after it be
+		// called without their vocabulary, as in using drums
+		"using": func(stack Stack, state State) (Stack, State, []string) {
+			tok, errs := parseName("using", state.tokens)
+			if errs != nil {
+				return stack, state, errs
+			}
+			if !state.vocabExists(tok.Text) {
+				return stack, state, []string{"Error: unknown vocabulary " + tok.Text}
+			}
+			return stack, state.useVocab(tok.Text), nil
+		},
+
+		// order ( -- ) prints the vocabularies in use, searched first to
+		// last, and the one words are being defined in
+		"order": func(stack Stack, state State) (Stack, State, []string) {
+			using := "none"
+			if len(state.Using) > 0 {
+				using = strings.Join(state.Using, " ")
+			}
+			defining := state.Vocab
+			if defining == "" {
+				defining = "none"
+			}
+			return stack, state, []string{fmt.Sprintf("using: %s, defining in: %s", using, defining)}
+		},
+	}
+}
@@ -0,0 +1,228 @@
+package forth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TokenKind says what sort of token the lexer found
+type TokenKind int
+
+const (
+	TokenWord    TokenKind = iota
+	TokenNumber            // Anything strconv.ParseFloat accepts
+	TokenString            // "double quoted", `backticked` or 'symbol
+	TokenSigil             // $name followed by its value, expanded when a nod fires
+	TokenBracket           // [ ] { }
+	TokenComment           // ( stack comment ), kept so definitions can be documented
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenNumber:
+		return "number"
+	case TokenString:
+		return "string"
+	case TokenSigil:
+		return "sigil"
+	case TokenBracket:
+		return "bracket"
+	case TokenComment:
+		return "comment"
+	default:
+		return "word"
+	}
+}
+
+// Token is a piece of forth source along with where it was found
+type Token struct {
+	Kind  TokenKind
+	Text  string // The token as written, lexing Text again gives the same token
+	Value string // Strings without their quotes and with escapes resolved, comments without their parens, otherwise Text
+	Line  int    // 1-based line the token starts on
+	Col   int    // 1-based column the token starts at, counted in characters
+}
+
+// Position describes where the token starts for error messages
+func (t Token) Position() string {
+	return fmt.Sprintf("line %d, column %d", t.Line, t.Col)
+}
+
+// LexError is a problem in the source, such as a string that is never closed
+type LexError struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (e *LexError) Error() string {
+	return fmt.Sprintf("%s at line %d, column %d", e.Msg, e.Line, e.Col)
+}
+
+// lexer walks source a character at a time keeping track of line and column
+type lexer struct {
+	src  []rune
+	pos  int
+	line int
+	col  int
+}
+
+// Lex splits forth source into tokens. Whitespace and \ line comments are
+// dropped, ( stack comments ) become comment tokens. Double quoted strings
+// understand \" \\ \n \t and \r, backticked strings only \`. Both may span
+// lines.
+func Lex(input string) ([]Token, error) {
+	return LexAt(input, 1, 1)
+}
+
+// LexAt lexes input taken from a larger source, where it starts at line and
+// col, so tokens and errors are positioned in that source
+func LexAt(input string, line, col int) ([]Token, error) {
+	l := &lexer{src: []rune(input), line: line, col: col}
+	var tokens []Token
+
+	for l.pos < len(l.src) {
+		r := l.src[l.pos]
+		switch {
+		case unicode.IsSpace(r):
+			l.advance()
+
+		case r == '(' && l.endsWord(1):
+			line, col, start := l.line, l.col, l.pos
+			for l.pos < len(l.src) && l.src[l.pos] != ')' {
+				l.advance()
+			}
+			if l.pos == len(l.src) {
+				return tokens, &LexError{Line: line, Col: col, Msg: "unterminated ( comment"}
+			}
+			l.advance()
+			tokens = append(tokens, Token{
+				Kind:  TokenComment,
+				Text:  string(l.src[start:l.pos]),
+				Value: strings.TrimSpace(string(l.src[start+1 : l.pos-1])),
+				Line:  line,
+				Col:   col,
+			})
+
+		case r == '\\' && l.endsWord(1):
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance()
+			}
+
+		case r == '"' || r == '`':
+			tok, err := l.lexString(r)
+			if err != nil {
+				return tokens, err
+			}
+			tokens = append(tokens, tok)
+
+		default:
+			tokens = append(tokens, l.lexWord())
+		}
+	}
+
+	return tokens, nil
+}
+
+func (l *lexer) advance() {
+	if l.src[l.pos] == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	l.pos++
+}
+
+// endsWord reports whether the character offset ahead is whitespace or the
+// end of the source, so ( and \ only start comments when they stand alone
+func (l *lexer) endsWord(offset int) bool {
+	i := l.pos + offset
+	return i >= len(l.src) || unicode.IsSpace(l.src[i])
+}
+
+// lexString reads a string closed by quote
+func (l *lexer) lexString(quote rune) (Token, error) {
+	line, col, start := l.line, l.col, l.pos
+	var value strings.Builder
+	l.advance()
+
+	for l.pos < len(l.src) {
+		r := l.src[l.pos]
+		if r == quote {
+			l.advance()
+			return Token{
+				Kind:  TokenString,
+				Text:  string(l.src[start:l.pos]),
+				Value: value.String(),
+				Line:  line,
+				Col:   col,
+			}, nil
+		}
+
+		if r == '\\' && l.pos+1 < len(l.src) {
+			if escaped, ok := unescape(quote, l.src[l.pos+1]); ok {
+				value.WriteRune(escaped)
+				l.advance()
+				l.advance()
+				continue
+			}
+		}
+
+		value.WriteRune(r)
+		l.advance()
+	}
+
+	return Token{}, &LexError{Line: line, Col: col, Msg: fmt.Sprintf("unterminated %c string", quote)}
+}
+
+// unescape resolves the character after a backslash. Backticked strings
+// usually hold code for other languages so they only escape backticks.
+func unescape(quote, r rune) (rune, bool) {
+	if quote == '`' {
+		return '`', r == '`'
+	}
+	switch r {
+	case '"', '\\':
+		return r, true
+	case 'n':
+		return '\n', true
+	case 't':
+		return '\t', true
+	case 'r':
+		return '\r', true
+	}
+	return 0, false
+}
+
+// lexWord reads up to the next whitespace or the start of a string
+func (l *lexer) lexWord() Token {
+	line, col, start := l.line, l.col, l.pos
+	for l.pos < len(l.src) {
+		r := l.src[l.pos]
+		if unicode.IsSpace(r) || r == '"' || r == '`' {
+			break
+		}
+		l.advance()
+	}
+
+	text := string(l.src[start:l.pos])
+	tok := Token{Kind: TokenWord, Text: text, Value: text, Line: line, Col: col}
+
+	switch {
+	case text == "[" || text == "]" || text == "{" || text == "}":
+		tok.Kind = TokenBracket
+	case strings.HasPrefix(text, "$") && len(text) > 1:
+		tok.Kind = TokenSigil
+	case strings.HasPrefix(text, "'") && len(text) > 1:
+		tok.Kind = TokenString
+		tok.Value = text[1:]
+	default:
+		if _, err := strconv.ParseFloat(text, 64); err == nil {
+			tok.Kind = TokenNumber
+		}
+	}
+	return tok
+}
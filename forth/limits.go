@@ -0,0 +1,126 @@
+package forth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Limits bounds a single evaluation so a runaway message can't hang the
+// clock. A zero field means no limit.
+type Limits struct {
+	MaxSteps int           // Tokens evaluated, counting those inside called words
+	MaxDepth int           // Words calling words calling words
+	MaxStack int           // Items on the stack
+	Timeout  time.Duration // Wall clock time for the whole evaluation
+}
+
+// DefaultLimits are the limits a new interpreter state starts with
+var DefaultLimits = Limits{
+	MaxSteps: 100000,
+	MaxDepth: 256,
+	MaxStack: 4096,
+	Timeout:  time.Second,
+}
+
+// LimitError reports which limit stopped an evaluation
+type LimitError struct {
+	Limit    string // "steps", "depth", "stack", "timeout" or "cancelled"
+	Msg      string
+	Position string // Where in the source the evaluation was stopped, if known
+}
+
+func (e *LimitError) Error() string {
+	if e.Position != "" {
+		return fmt.Sprintf("%s (%s)", e.Msg, e.Position)
+	}
+	return e.Msg
+}
+
+// IsLimitError reports whether err is, or wraps, a LimitError
+func IsLimitError(err error) bool {
+	var limitErr *LimitError
+	return errors.As(err, &limitErr)
+}
+
+// budget is what is left of the limits for one evaluation. It is shared by
+// every nested Interpret through State so called words count towards it.
+type budget struct {
+	ctx    context.Context
+	limits Limits
+	steps  int
+	err    *LimitError
+	done   atomic.Bool // States captured during an evaluation outlive it
+}
+
+// step charges one token to the budget and checks every limit
+func (b *budget) step(depth, stackSize int) *LimitError {
+	if b.err != nil {
+		return b.err
+	}
+
+	b.steps++
+	switch {
+	case b.limits.MaxDepth > 0 && depth > b.limits.MaxDepth:
+		b.err = &LimitError{Limit: "depth", Msg: fmt.Sprintf("words nested more than %d deep", b.limits.MaxDepth)}
+	case b.limits.MaxSteps > 0 && b.steps > b.limits.MaxSteps:
+		b.err = &LimitError{Limit: "steps", Msg: fmt.Sprintf("evaluation took more than %d steps", b.limits.MaxSteps)}
+	case b.checkStack(stackSize) != nil:
+	default:
+		switch b.ctx.Err() {
+		case nil:
+		case context.DeadlineExceeded:
+			b.err = &LimitError{Limit: "timeout", Msg: "evaluation timed out"}
+		default:
+			b.err = &LimitError{Limit: "cancelled", Msg: "evaluation cancelled"}
+		}
+	}
+	return b.err
+}
+
+// checkStack records an error if the stack has grown past its limit
+func (b *budget) checkStack(size int) *LimitError {
+	if b.err == nil && b.limits.MaxStack > 0 && size > b.limits.MaxStack {
+		b.err = &LimitError{Limit: "stack", Msg: fmt.Sprintf("stack grew past %d items", b.limits.MaxStack)}
+	}
+	return b.err
+}
+
+// Evaluate interprets input like Interpret, stopping when ctx is done or any
+// of the state's limits is exceeded. When a limit stops it the stack is left
+// as it was and the error is a *LimitError.
+func Evaluate(ctx context.Context, input string, stack Stack, state State) (Stack, State, []string, error) {
+	return EvaluateAt(ctx, input, 1, 1, stack, state)
+}
+
+// EvaluateAt is Evaluate for input taken from a larger source, such as a
+// selection in an editor, starting at line and col. Errors are located in
+// that source rather than in input.
+func EvaluateAt(ctx context.Context, input string, line, col int, stack Stack, state State) (Stack, State, []string, error) {
+	return evaluate(ctx, stack, state, func(stack Stack, state State) (Stack, State, []string) {
+		return interpret(input, line, col, stack, state)
+	})
+}
+
+// evaluate runs a word with a fresh budget made from the state's limits
+func evaluate(ctx context.Context, stack Stack, state State, run DictionaryWord) (Stack, State, []string, error) {
+	if state.Limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, state.Limits.Timeout)
+		defer cancel()
+	}
+
+	b := &budget{ctx: ctx, limits: state.Limits}
+	outer := state.budget
+	state.budget = b
+	newStack, newState, output := run(stack, state)
+	b.done.Store(true)
+	newState.budget = outer
+
+	if b.err != nil {
+		return stack, newState, output, b.err
+	}
+	return newStack, newState, output, nil
+}
@@ -0,0 +1,154 @@
+package forth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// localsSpec is a definition's locals, declared straight after its name as
+// : note { pitch vel -- } ... ;
+type localsSpec struct {
+	params  []string // Taken from the stack when the word is called, the last from the top
+	results []string // What the word leaves, only for documentation
+}
+
+// effect writes the declaration as a stack effect
+func (l *localsSpec) effect() string {
+	return "( " + strings.Join(append(append(append([]string{}, l.params...), "--"), l.results...), " ") + " )"
+}
+
+// parseLocals reads a locals declaration from words starting with its {,
+// returning how many words it took. Blocks without a -- aren't
+// declarations.
+func parseLocals(words []string) (*localsSpec, int) {
+	if len(words) == 0 || words[0] != "{" {
+		return nil, 0
+	}
+
+	spec := &localsSpec{}
+	seenDashes := false
+	for i := 1; i < len(words); i++ {
+		switch w := words[i]; {
+		case w == "}":
+			if !seenDashes {
+				return nil, 0
+			}
+			return spec, i + 1
+		case w == "--" && !seenDashes:
+			seenDashes = true
+		case !isLocalName(w):
+			return nil, 0
+		case seenDashes:
+			spec.results = append(spec.results, w)
+		default:
+			spec.params = append(spec.params, w)
+		}
+	}
+	return nil, 0
+}
+
+// isLocalName reports whether a word can name a local
+func isLocalName(w string) bool {
+	toks, err := Lex(w)
+	if err != nil || len(toks) != 1 || toks[0].Kind != TokenWord {
+		return false
+	}
+	_, isNote := ParseNoteName(w)
+	return !isNote && w != "--"
+}
+
+// splitLocals finds the locals declared at the start of a definition, after
+// any stack comments, and returns the definition without them
+func splitLocals(definition []string) (*localsSpec, []string) {
+	start := 0
+	for start < len(definition) {
+		toks, _ := Lex(definition[start])
+		if len(toks) != 1 || toks[0].Kind != TokenComment {
+			break
+		}
+		start++
+	}
+
+	spec, n := parseLocals(definition[start:])
+	if spec == nil {
+		return nil, definition
+	}
+	body := append(append([]string{}, definition[:start]...), definition[start+n:]...)
+	return spec, body
+}
+
+// bindLocals takes a word's parameters off the stack into a new frame
+func (l *localsSpec) bindLocals(name string, stack Stack) (Stack, map[string]StackItem, error) {
+	if len(stack) < len(l.params) {
+		return stack, nil, fmt.Errorf("stack underflow: %s takes %d values", name, len(l.params))
+	}
+
+	frame := make(map[string]StackItem, len(l.params))
+	s := stack
+	for i := len(l.params) - 1; i >= 0; i-- {
+		var value StackItem
+		s, value, _ = Pop(s)
+		frame[l.params[i]] = value
+	}
+	return s, frame, nil
+}
+
+// captured returns the block's tokens with the values of its locals written
+// in, for when it is written out as source
+func (q QuotedBlock) captured() []string {
+	return captureLocals(q.tokens, q.locals)
+}
+
+// captureLocals writes the values of locals into the tokens of a quotation,
+// so it still has them when it runs after the word has returned, such as in
+// a nod message. The local after to is left, so assigning to it fails there.
+func captureLocals(tokens []string, locals map[string]StackItem) []string {
+	if len(locals) == 0 {
+		return tokens
+	}
+
+	captured := make([]string, len(tokens))
+	for i, tok := range tokens {
+		value, ok := locals[tok]
+		// The local after to is being assigned, not read
+		if !ok || (i > 0 && tokens[i-1] == "to") {
+			captured[i] = tok
+			continue
+		}
+		captured[i] = sourceOf(value)
+	}
+	return captured
+}
+
+// localsDictionary creates the words for working with locals
+func localsDictionary() Dictionary {
+	return Dictionary{
+		// to ( x -- ) sets the local named after it, as in 64 to pitch
+		"to": func(stack Stack, state State) (Stack, State, []string) {
+			tok, errs := parseName("to", state.tokens)
+			if errs != nil {
+				return stack, state, errs
+			}
+			if _, ok := state.locals[tok.Text]; !ok {
+				return stack, state, []string{"Error: no local named " + tok.Text}
+			}
+			s, value, err := Pop(stack)
+			if err != nil {
+				return stack, state, []string{err.Error()}
+			}
+
+			state.locals[tok.Text] = value
+			return s, state, nil
+		},
+	}
+}
+
+// localsEffect is the stack effect of the locals declared in a definition,
+// if it declares any
+func localsEffect(definition []string) string {
+	spec, _ := splitLocals(definition)
+	if spec == nil {
+		return ""
+	}
+	return spec.effect()
+}
@@ -0,0 +1,52 @@
+package forth
+
+import (
+	"strconv"
+	"strings"
+)
+
+// pitchClasses maps note letters to semitones above C
+var pitchClasses = map[byte]int{
+	'c': 0, 'd': 2, 'e': 4, 'f': 5, 'g': 7, 'a': 9, 'b': 11,
+}
+
+// ParsePitchClass reads a note letter and its accidentals (c, f#, eb, ds)
+// and returns the semitone offset above C and the unparsed remainder
+func ParsePitchClass(word string) (int, string, bool) {
+	if word == "" {
+		return 0, word, false
+	}
+	pc, ok := pitchClasses[strings.ToLower(word[:1])[0]]
+	if !ok {
+		return 0, word, false
+	}
+
+	i := 1
+	for ; i < len(word); i++ {
+		switch word[i] {
+		case '#', 's':
+			pc++
+		case 'b':
+			pc--
+		default:
+			return pc, word[i:], true
+		}
+	}
+	return pc, word[i:], true
+}
+
+// ParseNoteName converts a note name with an octave (c4, f#3, eb-1) to a
+// MIDI note number, where c4 is 60
+func ParseNoteName(word string) (float64, bool) {
+	pc, rest, ok := ParsePitchClass(word)
+	if !ok || rest == "" {
+		return 0, false
+	}
+
+	octave, err := strconv.Atoi(rest)
+	if err != nil {
+		return 0, false
+	}
+
+	return float64((octave+1)*12 + pc), true
+}
@@ -0,0 +1,249 @@
+package forth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tokenStream is the tokens of one piece of source being interpreted.
+// Parsing words take tokens from it before the interpreter gets to them.
+type tokenStream struct {
+	tokens []Token
+	pos    int
+	done   bool // The interpret reading it has returned
+}
+
+// next returns the next token, comments included
+func (s *tokenStream) next() (Token, bool) {
+	if s == nil || s.done || s.pos >= len(s.tokens) {
+		return Token{}, false
+	}
+	tok := s.tokens[s.pos]
+	s.pos++
+	return tok, true
+}
+
+// nextWord returns the next token that isn't a comment
+func (s *tokenStream) nextWord() (Token, bool) {
+	for {
+		tok, ok := s.next()
+		if !ok || tok.Kind != TokenComment {
+			return tok, ok
+		}
+	}
+}
+
+// parseSpec is what a parsing word reads from the source after it, which
+// Check skips rather than reporting as unknown words
+type parseSpec struct {
+	names []bool // One per token read by name, true when : defines a word with it
+	until string // The word parse-until reads up to, "" if it isn't used
+}
+
+// parsingWords are the built in words that read the token after them
+var parsingWords = map[string]parseSpec{
+	"include":  {names: []bool{false}},
+	"vocab":    {names: []bool{false}},
+	"using":    {names: []bool{false}},
+	"postpone": {names: []bool{false}},
+	"to":       {names: []bool{false}},
+}
+
+// definitionParses works out what a word defined with : reads from the
+// source of its caller, from the parse-name, parse-until and : in its
+// definition. ok is false for words that don't parse.
+func definitionParses(definition []Token) (spec parseSpec, ok bool) {
+	for i := 0; i < len(definition); i++ {
+		tok := definition[i]
+		if tok.Kind != TokenWord {
+			continue
+		}
+		switch tok.Text {
+		case "postpone":
+			// The word after postpone is compiled rather than run
+			i++
+		case "parse-name", ":":
+			spec.names = append(spec.names, tok.Text == ":")
+			ok = true
+		case "parse-until":
+			if i > 0 && definition[i-1].Kind == TokenString {
+				spec.until = definition[i-1].Value
+				ok = true
+			}
+		}
+	}
+	return spec, ok
+}
+
+// parseName reads the token after a parsing word from source, or returns
+// an error line if there isn't one. Words read from state.input to take
+// their name from whoever called the word they are used in, as : does, or
+// from state.tokens to take it from where they are written, as include does.
+func parseName(word string, source *tokenStream) (Token, []string) {
+	if tok, ok := source.nextWord(); ok {
+		return tok, nil
+	}
+	return Token{}, []string{fmt.Sprintf("Error: %s expects a name after it", word)}
+}
+
+// isImmediate reports whether a word runs while a definition is being
+// compiled rather than being compiled into it
+func (s State) isImmediate(word string) bool {
+	name, ok := s.Resolve(word)
+	return ok && s.Immediate[name]
+}
+
+// sourceOf writes a stack item as forth source that pushes it again
+func sourceOf(item StackItem) string {
+	switch v := item.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+		return `"` + r.Replace(v) + `"`
+	case []interface{}:
+		parts := []string{"["}
+		for _, elem := range v {
+			parts = append(parts, sourceOf(elem))
+		}
+		return strings.Join(append(parts, "]"), " ")
+	case QuotedBlock:
+		return strings.Join(append(append([]string{"{"}, v.captured()...), "}"), " ")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// compileDictionary creates the words for extending the compiler from
+// forth: immediate words that run during a definition, words that parse the
+// source after them, and words that add to the definition being compiled.
+// With them a defining word such as
+//
+//	: pattern: ( -- ) : ";" parse-until postpone literal postpone ; ;
+//
+// makes pattern: kick "36 drum" "" "38 drum" ; define kick ( -- arr ) to
+// push those messages, ready to be laid down with seq.
+func compileDictionary() Dictionary {
+	return Dictionary{
+		// immediate ( -- ) makes the word just defined run while later
+		// definitions are compiled, instead of being compiled into them
+		"immediate": func(stack Stack, state State) (Stack, State, []string) {
+			if state.lastWord == "" {
+				return stack, state, []string{"Error: no word has been defined to make immediate"}
+			}
+			state.Immediate[state.lastWord] = true
+			return stack, state, nil
+		},
+
+		// postpone ( -- ) compiles the word after it even if it is immediate.
+		// A word that isn't immediate is compiled into the definition being
+		// compiled when the word being defined runs.
+		"postpone": func(stack Stack, state State) (Stack, State, []string) {
+			if state.CurrentWord == nil {
+				return stack, state, []string{"Error: postpone outside a definition"}
+			}
+			tok, errs := parseName("postpone", state.input)
+			if errs != nil {
+				return stack, state, errs
+			}
+			name, ok := state.Resolve(tok.Text)
+			if !ok {
+				return stack, state, []string{"Error: unknown word " + tok.Text}
+			}
+
+			newState := state
+			if state.Immediate[name] {
+				newState.CurrentDefinition = append(newState.CurrentDefinition, name)
+			} else {
+				newState.CurrentDefinition = append(newState.CurrentDefinition, sourceOf(name), "compile,")
+			}
+			return stack, newState, nil
+		},
+
+		// compile, ( 'word -- ) adds a word to the definition being compiled
+		"compile,": func(stack Stack, state State) (Stack, State, []string) {
+			name, s, err := PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			if state.CurrentWord == nil {
+				return stack, state, []string{"Error: compile, outside a definition"}
+			}
+
+			newState := state
+			newState.CurrentDefinition = append(newState.CurrentDefinition, name)
+			return s, newState, nil
+		},
+
+		// literal ( x -- ) compiles code that pushes x into the definition
+		// being compiled, as in : secs [[ 60 60 + ]] literal ;
+		"literal": func(stack Stack, state State) (Stack, State, []string) {
+			if state.CurrentWord == nil {
+				return stack, state, []string{"Error: literal outside a definition"}
+			}
+			s, item, err := Pop(stack)
+			if err != nil {
+				return stack, state, []string{err.Error()}
+			}
+
+			newState := state
+			newState.CurrentDefinition = append(newState.CurrentDefinition, sourceOf(item))
+			return s, newState, nil
+		},
+
+		// [[ ( -- ) stops compiling so the words up to ]] run now, while the
+		// definition is being compiled
+		"[[": func(stack Stack, state State) (Stack, State, []string) {
+			if !state.Compiling {
+				return stack, state, []string{"Error: [[ outside a definition"}
+			}
+			newState := state
+			newState.Compiling = false
+			return stack, newState, nil
+		},
+
+		// ]] ( -- ) goes back to compiling the definition [[ stopped
+		"]]": func(stack Stack, state State) (Stack, State, []string) {
+			if state.Compiling || state.CurrentWord == nil {
+				return stack, state, []string{"Error: ]] without a matching [["}
+			}
+			newState := state
+			newState.Compiling = true
+			newState.compileFrom = state.input
+			return stack, newState, nil
+		},
+
+		// parse-name ( -- "name" ) reads the token after the word that called
+		// it from the source, for defining words made with :
+		"parse-name": func(stack Stack, state State) (Stack, State, []string) {
+			tok, ok := state.input.nextWord()
+			if !ok {
+				return stack, state, []string{"Error: parse-name found nothing left to read"}
+			}
+			return Push(stack, tok.Value), state, nil
+		},
+
+		// parse-until ( "end" -- arr ) reads the tokens up to the word end
+		// from the source, as parse-name does, and pushes them as strings.
+		// The end word is read but left out.
+		"parse-until": func(stack Stack, state State) (Stack, State, []string) {
+			end, s, err := PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			parsed := make([]interface{}, 0)
+			for {
+				tok, ok := state.input.nextWord()
+				if !ok {
+					return stack, state, []string{fmt.Sprintf("Error: parse-until found no %s", end)}
+				}
+				if tok.Text == end && tok.Kind != TokenString {
+					return Push(s, parsed), state, nil
+				}
+				parsed = append(parsed, tok.Value)
+			}
+		},
+	}
+}
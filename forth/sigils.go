@@ -2,60 +2,398 @@ package forth
 
 import (
 	"fmt"
+	"hash/fnv"
+	"math"
 	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
+	"unicode"
 )
 
-func processSigil(sigilType string, value string) (string, error) {
-	switch sigilType {
-	case "r":
-		parts := strings.Split(value, ":")
-		if len(parts) != 2 {
-			return "", fmt.Errorf("invalid range format: expected format <number>:<number>, got %q", value)
+// Sigil expands the text following a sigil name into forth source
+type Sigil func(value string, call *SigilCall) (string, error)
+
+// SigilCall describes one sigil being expanded. Key identifies the
+// occurrence within its message, Env carries state that lives across bangs,
+// such as the position of a $s step, and Context is nil outside a hed.
+type SigilCall struct {
+	Key     string
+	Env     *SigilEnv
+	Context *EvalContext
+}
+
+// Next counts an expansion of this occurrence and returns the previous count
+func (c *SigilCall) Next() int {
+	return c.Env.Next(c.Key)
+}
+
+// SigilEnv holds per nod state for stateful sigils. A nil env is allowed and
+// behaves as if every sigil was being expanded for the first time.
+type SigilEnv struct {
+	counters map[string]int
+	mu       sync.Mutex
+}
+
+// NewSigilEnv creates an empty sigil environment
+func NewSigilEnv() *SigilEnv {
+	return &SigilEnv{counters: make(map[string]int)}
+}
+
+// Next returns how many times key has been expanded before and counts this one
+func (e *SigilEnv) Next(key string) int {
+	if e == nil {
+		return 0
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := e.counters[key]
+	e.counters[key] = n + 1
+	return n
+}
+
+var (
+	sigils   = map[string]Sigil{}
+	sigilsMu sync.RWMutex
+)
+
+func init() {
+	sigils["r"] = rangeSigil
+	sigils["f"] = floatSigil
+	sigils["d"] = diceSigil
+	sigils["c"] = chooseSigil
+	sigils["w"] = weightedSigil
+	sigils["s"] = stepSigil
+	sigils["n"] = noiseSigil
+	sigils["h"] = hedStepSigil
+	sigils["y"] = cycleSigil
+	sigils["a"] = accentSigil
+}
+
+// RegisterSigil adds or replaces a sigil. Names are made of letters so the
+// value that follows can be told apart from the name.
+func RegisterSigil(name string, sigil Sigil) error {
+	if name == "" {
+		return fmt.Errorf("sigil name cannot be empty")
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) {
+			return fmt.Errorf("sigil name %q must only contain letters", name)
 		}
-		start, err := strconv.Atoi(parts[0])
-		if err != nil {
-			return "", fmt.Errorf("invalid start number: %v", err)
+	}
+
+	sigilsMu.Lock()
+	defer sigilsMu.Unlock()
+	sigils[name] = sigil
+	return nil
+}
+
+// SigilNames returns the registered sigil names in sorted order
+func SigilNames() []string {
+	sigilsMu.RLock()
+	defer sigilsMu.RUnlock()
+
+	names := make([]string, 0, len(sigils))
+	for name := range sigils {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupSigil finds the longest registered name that prefixes word
+func lookupSigil(word string) (string, Sigil, bool) {
+	sigilsMu.RLock()
+	defer sigilsMu.RUnlock()
+
+	end := 0
+	for end < len(word) && unicode.IsLetter(rune(word[end])) {
+		end++
+	}
+	for ; end > 0; end-- {
+		if sigil, ok := sigils[word[:end]]; ok {
+			return word[:end], sigil, true
 		}
-		end, err := strconv.Atoi(parts[1])
-		if err != nil {
-			return "", fmt.Errorf("invalid end number: %v", err)
+	}
+	return "", nil, false
+}
+
+// splitList splits a comma separated sigil value, rejecting empty items
+func splitList(value string) ([]string, error) {
+	items := strings.Split(value, ",")
+	for _, item := range items {
+		if item == "" {
+			return nil, fmt.Errorf("invalid list %q: expected <item>,<item>,...", value)
+		}
+	}
+	return items, nil
+}
+
+// $r<start>:<end> random integer in an inclusive range
+func rangeSigil(value string, call *SigilCall) (string, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid range format: expected format <number>:<number>, got %q", value)
+	}
+	start, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("invalid start number: %v", err)
+	}
+	end, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid end number: %v", err)
+	}
+	if start > end {
+		start, end = end, start
+	}
+	return strconv.Itoa(rand.Intn(end-start+1) + start), nil
+}
+
+// $f<start>:<end> random float in a range
+func floatSigil(value string, call *SigilCall) (string, error) {
+	start, end, err := parseFloatRange(value)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(start+rand.Float64()*(end-start), 'g', -1, 64), nil
+}
+
+// $d<sides> or $d<count>d<sides> rolls and sums dice
+func diceSigil(value string, call *SigilCall) (string, error) {
+	count, sides := 1, value
+	if i := strings.Index(value, "d"); i >= 0 {
+		n, err := strconv.Atoi(value[:i])
+		if err != nil || n < 1 {
+			return "", fmt.Errorf("invalid dice count in %q", value)
+		}
+		count, sides = n, value[i+1:]
+	}
+	n, err := strconv.Atoi(sides)
+	if err != nil || n < 1 {
+		return "", fmt.Errorf("invalid dice sides in %q", value)
+	}
+	total := 0
+	for i := 0; i < count; i++ {
+		total += rand.Intn(n) + 1
+	}
+	return strconv.Itoa(total), nil
+}
+
+// $c<a>,<b>,<c> chooses one item at random
+func chooseSigil(value string, call *SigilCall) (string, error) {
+	items, err := splitList(value)
+	if err != nil {
+		return "", err
+	}
+	return items[rand.Intn(len(items))], nil
+}
+
+// $w<a>:<weight>,<b>:<weight> chooses one item in proportion to its weight
+func weightedSigil(value string, call *SigilCall) (string, error) {
+	items, err := splitList(value)
+	if err != nil {
+		return "", err
+	}
+
+	choices := make([]string, len(items))
+	weights := make([]float64, len(items))
+	total := 0.0
+	for i, item := range items {
+		sep := strings.LastIndex(item, ":")
+		if sep < 0 {
+			return "", fmt.Errorf("invalid weighted item %q: expected <item>:<weight>", item)
 		}
-		if start > end {
-			start, end = end, start
+		w, err := strconv.ParseFloat(item[sep+1:], 64)
+		if err != nil || w < 0 {
+			return "", fmt.Errorf("invalid weight in %q", item)
 		}
-		r := rand.New(rand.NewSource(time.Now().UnixNano()))
-		return strconv.Itoa(r.Intn((end - start + 1)) + start), nil
+		choices[i], weights[i] = item[:sep], w
+		total += w
+	}
+	if total == 0 {
+		return "", fmt.Errorf("weights in %q add up to zero", value)
+	}
 
-	// Easy to add new sigil types:
-	// case "d":
-	//     sides, err := strconv.Atoi(value)
-	//     if err != nil {
-	//         return "", fmt.Errorf("invalid dice sides: %v", err)
-	//     }
-	//     r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	//     return strconv.Itoa(r.Intn(sides) + 1), nil
+	r := rand.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return choices[i], nil
+		}
+		r -= w
+	}
+	return choices[len(choices)-1], nil
+}
 
-	default:
-		return "", fmt.Errorf("unknown sigil type %q", sigilType)
+// $s<a>,<b>,<c> steps through the items, one per expansion
+func stepSigil(value string, call *SigilCall) (string, error) {
+	items, err := splitList(value)
+	if err != nil {
+		return "", err
 	}
+	return items[call.Next()%len(items)], nil
 }
 
-func ParseSigils(input string) (string, error) {
-	words := strings.Split(input, " ")
-	for i, word := range words {
-		if strings.HasPrefix(word, "$") {
-			sigilType := word[1:2]
-			value := word[2:]
+// $n<min>:<max>[:<speed>] smooth value noise that drifts between expansions
+func noiseSigil(value string, call *SigilCall) (string, error) {
+	speed := 0.1
+	if parts := strings.Split(value, ":"); len(parts) == 3 {
+		s, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid noise speed: %v", err)
+		}
+		speed = s
+		value = parts[0] + ":" + parts[1]
+	}
+	start, end, err := parseFloatRange(value)
+	if err != nil {
+		return "", err
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(call.Key))
+	seed := h.Sum64()
+
+	t := float64(call.Next()) * speed
+	return strconv.FormatFloat(start+valueNoise(seed, t)*(end-start), 'g', -1, 64), nil
+}
+
+// $h<a>,<b>,<c> steps through the items once per hed bang, so every nod a
+// hed visits shares one position
+func hedStepSigil(value string, call *SigilCall) (string, error) {
+	items, err := splitList(value)
+	if err != nil {
+		return "", err
+	}
+	n := 0
+	if call.Context != nil && call.Context.Bangs > 0 {
+		n = call.Context.Bangs - 1
+	}
+	return items[n%len(items)], nil
+}
+
+// $y<a>,<b>,<c> picks an item by the hed's cycle number
+func cycleSigil(value string, call *SigilCall) (string, error) {
+	items, err := splitList(value)
+	if err != nil {
+		return "", err
+	}
+	n := 0
+	if call.Context != nil {
+		n = call.Context.Cycle
+	}
+	return items[n%len(items)], nil
+}
+
+// $a<every>:<accent>:<normal> gives accent on every nth cycle of the hed,
+// starting with the first, and normal otherwise
+func accentSigil(value string, call *SigilCall) (string, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("invalid accent format: expected format <every>:<accent>:<normal>, got %q", value)
+	}
+	every, err := strconv.Atoi(parts[0])
+	if err != nil || every < 1 {
+		return "", fmt.Errorf("invalid accent interval %q", parts[0])
+	}
+	cycle := 0
+	if call.Context != nil {
+		cycle = call.Context.Cycle
+	}
+	if cycle%every == 0 {
+		return parts[1], nil
+	}
+	return parts[2], nil
+}
+
+func parseFloatRange(value string) (float64, float64, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid range format: expected format <number>:<number>, got %q", value)
+	}
+	start, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid start number: %v", err)
+	}
+	end, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid end number: %v", err)
+	}
+	return start, end, nil
+}
 
-			result, err := processSigil(sigilType, value)
-			if err != nil {
-				return "", fmt.Errorf("error processing sigil %q: %v", word, err)
-			}
-			words[i] = result
+// valueNoise returns smooth noise in [0,1) by easing between random values
+// placed at whole numbers
+func valueNoise(seed uint64, t float64) float64 {
+	lattice := func(i int64) float64 {
+		return rand.New(rand.NewSource(int64(seed) ^ i*0x5851f42d4c957f2d)).Float64()
+	}
+	i := int64(math.Floor(t))
+	f := t - float64(i)
+	f = f * f * (3 - 2*f)
+	return lattice(i)*(1-f) + lattice(i+1)*f
+}
+
+// ExpandSigils replaces every sigil token in input with its expansion.
+// Sigils are only expanded where the lexer finds them, so a $ inside a
+// string or a comment is left as written, as in "cost $5". Everything else,
+// including whitespace, is left as it was. Input that doesn't lex is
+// returned unchanged so running it reports the problem. ctx may be nil.
+func ExpandSigils(input string, env *SigilEnv, ctx *EvalContext) (string, error) {
+	tokens, err := Lex(input)
+	if err != nil {
+		return input, nil
+	}
+
+	// Tokens are positioned by line and column, so find where each line
+	// starts to turn them back into offsets
+	runes := []rune(input)
+	lineStarts := []int{0}
+	for i, r := range runes {
+		if r == '\n' {
+			lineStarts = append(lineStarts, i+1)
 		}
 	}
-	return strings.Join(words, " "), nil
+
+	var out strings.Builder
+	last := 0
+	occurrence := 0
+	for _, tok := range tokens {
+		if tok.Kind != TokenSigil {
+			continue
+		}
+		word := tok.Text
+
+		name, sigil, ok := lookupSigil(word[1:])
+		if !ok {
+			return "", fmt.Errorf("unknown sigil %q", word)
+		}
+
+		// The key identifies this occurrence within the message so stateful
+		// sigils keep separate positions
+		call := &SigilCall{
+			Key:     fmt.Sprintf("%d:%s", occurrence, word),
+			Env:     env,
+			Context: ctx,
+		}
+		occurrence++
+
+		result, err := sigil(word[1+len(name):], call)
+		if err != nil {
+			return "", fmt.Errorf("error processing sigil %q: %v", word, err)
+		}
+
+		at := lineStarts[tok.Line-1] + tok.Col - 1
+		out.WriteString(string(runes[last:at]))
+		out.WriteString(result)
+		last = at + len([]rune(word))
+	}
+	out.WriteString(string(runes[last:]))
+
+	return out.String(), nil
+}
+
+// ParseSigils expands sigils without any state carried between calls
+func ParseSigils(input string) (string, error) {
+	return ExpandSigils(input, nil, nil)
 }
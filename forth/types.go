@@ -1,5 +1,7 @@
 package forth
 
+import "strings"
+
 // StackItem represents items that can be stored on the stack
 type StackItem interface{}
 
@@ -15,13 +17,52 @@ type Dictionary map[string]DictionaryWord
 // State maintains the interpreter's state
 type State struct {
 	Dictionary        Dictionary
+	Docs              WordDocs        // Documentation for words in Dictionary, shared like it
+	Immediate         map[string]bool // Words that run while a definition is compiled, shared like Dictionary
 	Compiling         bool
-	CollectingBlock   bool // Add this new field
 	CurrentDefinition []string
 	CurrentWord       *string
 	Globals           map[string]StackItem // Add this new field
+	Key               float64              // Root note used by degree and quantize words
+	Scale             string               // Scale name used by degree and quantize words
+	Context           *EvalContext         // Where a nod message is running from, nil in the editor
+	Limits            Limits               // Bounds on each evaluation
+	Dir               string               // Directory relative includes are found from, empty for the working directory
+	Included          map[string]bool      // Absolute paths of files already included, shared like Globals
+	Roots             []string             // Directories forth may read and write files in, anywhere when empty
+	Vocab             string               // Vocabulary new definitions go in, empty for none
+	Using             []string             // Vocabularies searched for words before the rest, first to last
+	tokens            *tokenStream         // Source the running word was called from
+	input             *tokenStream         // Source parsing words read from
+	parseCaller       bool                 // The next source is a word's body, whose parsing words read from input
+	compileFrom       *tokenStream         // Source the definition being compiled is read from
+	lastWord          string               // Word most recently defined with :
+	locals            map[string]StackItem // Locals of the word running, one map per call
+	depth             int                  // How many words deep Interpret has been called
+	budget            *budget              // Limits left for the running evaluation
+}
+
+// EvalContext is a read-only description of the hed and nod a message is
+// being evaluated for
+type EvalContext struct {
+	HedID string
+	NodID string
+	NodX  int
+	NodY  int
+	Bangs int // Times the hed has fired, counting this one
+	Cycle int // Times the hed has wrapped back to its first nod
+	Step  int // Position of the nod counted from the hed's first nod
+	Tick  int // Clock tick the message is running on
 }
 
 type QuotedBlock struct {
 	tokens []string
+	locals map[string]StackItem // Locals of the word the block was made in, if any
+}
+
+// Source returns the block's tokens as forth source that can be interpreted.
+// Locals are replaced by their values, so the source still runs after the
+// word the block was made in has returned.
+func (q QuotedBlock) Source() string {
+	return strings.Join(q.captured(), " ")
 }
@@ -0,0 +1,260 @@
+package forth
+
+import (
+	"embed"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// WordInfo documents a dictionary word
+type WordInfo struct {
+	Name        string `json:"name"`
+	Effect      string `json:"effect,omitempty"` // Stack effect such as ( y x -- y x )
+	Description string `json:"description,omitempty"`
+	Category    string `json:"category"`
+	Source      string `json:"source,omitempty"` // The definition of words made with :
+}
+
+// Summary describes the word on one line
+func (w WordInfo) Summary() string {
+	parts := []string{w.Name}
+	if w.Effect != "" {
+		parts = append(parts, w.Effect)
+	}
+	if w.Description != "" {
+		parts = append(parts, w.Description)
+	}
+	return fmt.Sprintf("%s [%s]", strings.Join(parts, " "), w.Category)
+}
+
+// WordDocs maps word names to their documentation
+type WordDocs map[string]WordInfo
+
+// CategoryUser is the category of words made with :
+const CategoryUser = "user"
+
+// categoryOther is reported for words nothing has documented
+const categoryOther = "other"
+
+//go:embed forth.go words.go include.go parsing.go locals.go
+var coreSources embed.FS
+
+// coreDocs are read once from the comments on the words defined in this
+// package
+var coreDocs = sync.OnceValue(func() WordDocs {
+	docs := make(WordDocs)
+	for file, category := range map[string]string{"forth.go": "core", "words.go": "introspection", "include.go": "include", "parsing.go": "compile", "locals.go": "locals"} {
+		src, err := coreSources.ReadFile(file)
+		if err == nil {
+			var fileDocs WordDocs
+			fileDocs, err = DocsFromSource(category, src)
+			for name, info := range fileDocs {
+				docs[name] = info
+			}
+		}
+		if err != nil {
+			panic(fmt.Sprintf("reading word docs from %s: %v", file, err))
+		}
+	}
+	return docs
+})
+
+// DocsFromSource documents the words in a Go file's dictionary literals from
+// the comment above each word, written as
+//
+//	// name ( before -- after ) what the word does
+//
+// Words without a comment are still listed under the category.
+func DocsFromSource(category string, src []byte) (WordDocs, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	// Comments by the line they end on, so each word finds the one above it
+	above := make(map[int]*ast.CommentGroup)
+	for _, group := range file.Comments {
+		above[fset.Position(group.End()).Line] = group
+	}
+
+	docs := make(WordDocs)
+	ast.Inspect(file, func(n ast.Node) bool {
+		kv, ok := n.(*ast.KeyValueExpr)
+		if !ok {
+			return true
+		}
+		key, ok := kv.Key.(*ast.BasicLit)
+		if _, isFunc := kv.Value.(*ast.FuncLit); !ok || !isFunc || key.Kind != token.STRING {
+			return true
+		}
+		name, err := strconv.Unquote(key.Value)
+		if err != nil {
+			return true
+		}
+
+		info := WordInfo{Name: name, Category: category}
+		if group, ok := above[fset.Position(kv.Pos()).Line-1]; ok {
+			info.Effect, info.Description = parseDoc(name, group.Text())
+		}
+		docs[name] = info
+		return false
+	})
+	return docs, nil
+}
+
+// parseDoc splits a word's comment into its stack effect and description
+func parseDoc(name, text string) (effect, description string) {
+	text = strings.Join(strings.Fields(text), " ")
+	text = strings.TrimPrefix(text, name+" ")
+	if strings.HasPrefix(text, "(") {
+		if end := strings.Index(text, ")"); end > 0 {
+			return text[:end+1], strings.TrimSpace(text[end+1:])
+		}
+	}
+	return "", text
+}
+
+// documentDefinition describes a word made with :, taking its stack effect
+// and description from the ( comments ) that open the definition
+func documentDefinition(name string, definition []string) WordInfo {
+	body := strings.Join(definition, " ")
+	info := WordInfo{
+		Name:     name,
+		Category: CategoryUser,
+		Source:   strings.Join(strings.Fields(": "+name+" "+body+" ;"), " "),
+	}
+
+	tokens, _ := Lex(body)
+	var description []string
+	for _, tok := range tokens {
+		if tok.Kind != TokenComment {
+			break
+		}
+		if info.Effect == "" && strings.Contains(tok.Value, "--") {
+			info.Effect = tok.Text
+		} else {
+			description = append(description, tok.Value)
+		}
+	}
+	info.Description = strings.Join(description, " ")
+	if info.Effect == "" {
+		info.Effect = localsEffect(definition)
+	}
+	return info
+}
+
+// AddDocs documents words, replacing whatever was known about them
+func (s State) AddDocs(docs WordDocs) {
+	for name, info := range docs {
+		s.Docs[name] = info
+	}
+}
+
+// Describe returns the documentation for a word in the dictionary, found
+// through the vocabularies in use
+func (s State) Describe(name string) (WordInfo, bool) {
+	name, ok := s.Resolve(name)
+	if !ok {
+		return WordInfo{}, false
+	}
+	info, ok := s.Docs[name]
+	if !ok {
+		info = WordInfo{Name: name, Category: categoryOther}
+	}
+	return info, true
+}
+
+// Words documents every word in the dictionary, sorted by name
+func (s State) Words() []WordInfo {
+	words := make([]WordInfo, 0, len(s.Dictionary))
+	for name := range s.Dictionary {
+		info, _ := s.Describe(name)
+		words = append(words, info)
+	}
+	sort.Slice(words, func(i, j int) bool { return words[i].Name < words[j].Name })
+	return words
+}
+
+// introspectionDictionary creates words for finding out about the dictionary
+func introspectionDictionary() Dictionary {
+	return Dictionary{
+		// words ( -- ) lists every word, a line for each category
+		"words": func(stack Stack, state State) (Stack, State, []string) {
+			byCategory := make(map[string][]string)
+			for _, info := range state.Words() {
+				byCategory[info.Category] = append(byCategory[info.Category], info.Name)
+			}
+
+			categories := make([]string, 0, len(byCategory))
+			for category := range byCategory {
+				categories = append(categories, category)
+			}
+			sort.Strings(categories)
+
+			output := make([]string, len(categories))
+			for i, category := range categories {
+				output[i] = fmt.Sprintf("%s: %s", category, strings.Join(byCategory[category], " "))
+			}
+			return stack, state, output
+		},
+
+		// see ( 'word -- ) shows the definition of a word made with :
+		"see": func(stack Stack, state State) (Stack, State, []string) {
+			name, s, err := PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			info, ok := state.Describe(name)
+			if !ok {
+				return stack, state, []string{"Error: unknown word " + name}
+			}
+			if info.Source == "" {
+				return s, state, []string{fmt.Sprintf("%s is a built in %s word", name, info.Category)}
+			}
+			return s, state, []string{info.Source}
+		},
+
+		// help ( 'word -- ) shows a word's stack effect and what it does
+		"help": func(stack Stack, state State) (Stack, State, []string) {
+			name, s, err := PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			info, ok := state.Describe(name)
+			if !ok {
+				return stack, state, []string{"Error: unknown word " + name}
+			}
+			return s, state, []string{info.Summary()}
+		},
+
+		// apropos ( "text" -- ) lists the words whose name or description
+		// mentions text
+		"apropos": func(stack Stack, state State) (Stack, State, []string) {
+			text, s, err := PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			text = strings.ToLower(text)
+			var output []string
+			for _, info := range state.Words() {
+				if strings.Contains(strings.ToLower(info.Name), text) || strings.Contains(strings.ToLower(info.Description), text) {
+					output = append(output, info.Summary())
+				}
+			}
+			if len(output) == 0 {
+				output = []string{fmt.Sprintf("no words mention %q", text)}
+			}
+			return s, state, output
+		},
+	}
+}
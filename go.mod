@@ -2,4 +2,4 @@ module 3body
 
 go 1.23.3
 
-require github.com/hypebeast/go-osc v0.0.0-20220308234300-cec5a8a1e5f5 // indirect
+require github.com/hypebeast/go-osc v0.0.0-20220308234300-cec5a8a1e5f5
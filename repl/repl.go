@@ -0,0 +1,299 @@
+// Package repl serves a 3body session over TCP or unix sockets so editors
+// and terminals can be frontends without a browser.
+//
+// Each line a client sends is a request. A line holding a JSON object is
+// answered with JSON objects, one per line:
+//
+//	{"id": "1", "op": "eval", "code": "1 2 +"}
+//	{"id": "1", "status": "done", "stack": [3]}
+//
+//	{"id": "2", "op": "complete", "prefix": "hed-"}
+//	{"id": "2", "status": "done", "completions": [{"name": "hed-first", ...}]}
+//
+//	{"id": "3", "op": "interrupt", "target": "1"}
+//	{"id": "3", "status": "done", "interrupted": ["1"]}
+//
+// Evaluations run one at a time in the order they arrive. interrupt cancels
+// the evaluation with the target id, or every evaluation the connection has
+// waiting or running when there is no target. Failed requests are answered
+// with "status": "error" and an "error" message.
+//
+// While the clock runs, output printed by nod messages and hed errors are
+// sent to every connection as they happen:
+//
+//	{"event": "output", "hed": "0,0", "nod": "1,0", "tick": 12, "lines": ["3"]}
+//	{"event": "error", "hed": "0,0", "tick": 13, "lines": ["..."]}
+//
+// Any other line is evaluated as forth source and answered in plain text,
+// with the output followed by "ok" and the stack, so nc or telnet work as a
+// simple terminal. Events are then sent to the connection as text too.
+package repl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"3body/connections"
+	"3body/forth"
+)
+
+// Session is the forth session the REPL evaluates in, shared with the other
+// frontends
+type Session interface {
+	// Evaluate runs source against the shared stack, stopping early if ctx
+	// is done
+	Evaluate(ctx context.Context, source string) (forth.Stack, []string, error)
+	// Words documents every word in the dictionary
+	Words() []forth.WordInfo
+}
+
+// Request is a JSON request from a client
+type Request struct {
+	ID     string `json:"id"`
+	Op     string `json:"op"`               // "eval", "complete" or "interrupt"
+	Code   string `json:"code,omitempty"`   // Source for eval
+	Prefix string `json:"prefix,omitempty"` // What has been typed so far for complete
+	Target string `json:"target,omitempty"` // The eval to cancel for interrupt
+}
+
+// Response answers a Request
+type Response struct {
+	ID          string           `json:"id"`
+	Status      string           `json:"status"` // "done" or "error"
+	Output      []string         `json:"output,omitempty"`
+	Stack       forth.Stack      `json:"stack,omitempty"`
+	Completions []forth.WordInfo `json:"completions,omitempty"`
+	Interrupted []string         `json:"interrupted,omitempty"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// Listen opens a socket for Serve. Addresses starting with unix: or
+// containing a / are unix sockets, anything else is a TCP host:port.
+func Listen(address string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(address, "unix:"); ok || strings.Contains(address, "/") {
+		if !ok {
+			path = address
+		}
+		// A socket left behind by a server that didn't shut down cleanly
+		// would stop us listening
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", address)
+}
+
+// Serve accepts connections until the listener is closed
+func Serve(l net.Listener, session Session) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go newClient(c, session).serve()
+	}
+}
+
+// client is one connection
+type client struct {
+	conn    net.Conn
+	session Session
+	plain   atomic.Bool // Whether the client last spoke plain text rather than JSON
+
+	writeMu sync.Mutex
+
+	// evals holds the cancel functions of evaluations waiting or running,
+	// last is closed when the most recent one finishes
+	evals   map[string]context.CancelFunc
+	last    chan struct{}
+	evalsMu sync.Mutex
+	pending sync.WaitGroup
+}
+
+func newClient(c net.Conn, session Session) *client {
+	return &client{conn: c, session: session, evals: make(map[string]context.CancelFunc)}
+}
+
+func (c *client) serve() {
+	defer c.conn.Close()
+
+	events, unsubscribe := connections.Subscribe()
+	defer unsubscribe()
+	go func() {
+		for e := range events {
+			c.sendEvent(e)
+		}
+	}()
+
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "{") {
+			c.plain.Store(true)
+			c.evalPlain(line)
+			continue
+		}
+
+		c.plain.Store(false)
+		var req Request
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			c.send(Response{Status: "error", Error: fmt.Sprintf("invalid request: %v", err)})
+			continue
+		}
+		c.handle(req)
+	}
+
+	// Nothing is left to answer, so stop anything still waiting
+	c.cancel("")
+	c.pending.Wait()
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		log.Printf("repl connection from %s: %v", c.conn.RemoteAddr(), err)
+	}
+}
+
+func (c *client) handle(req Request) {
+	switch req.Op {
+	case "eval":
+		ctx, cancel := context.WithCancel(context.Background())
+		c.evalsMu.Lock()
+		if _, running := c.evals[req.ID]; running {
+			c.evalsMu.Unlock()
+			cancel()
+			c.send(Response{ID: req.ID, Status: "error", Error: fmt.Sprintf("an eval with id %q is already running", req.ID)})
+			return
+		}
+		c.evals[req.ID] = cancel
+		previous, done := c.last, make(chan struct{})
+		c.last = done
+		c.evalsMu.Unlock()
+
+		// Evaluations run apart from the read loop so they can be
+		// interrupted, each waiting for the one before it
+		c.pending.Add(1)
+		go func() {
+			defer c.pending.Done()
+			defer close(done)
+			defer c.finish(req.ID)
+			if previous != nil {
+				<-previous
+			}
+
+			stack, output, err := c.session.Evaluate(ctx, req.Code)
+			if err != nil {
+				c.send(Response{ID: req.ID, Status: "error", Output: output, Error: err.Error()})
+				return
+			}
+			c.send(Response{ID: req.ID, Status: "done", Output: output, Stack: stack})
+		}()
+
+	case "interrupt":
+		c.send(Response{ID: req.ID, Status: "done", Interrupted: c.cancel(req.Target)})
+
+	case "complete":
+		var completions []forth.WordInfo
+		for _, info := range c.session.Words() {
+			if strings.HasPrefix(info.Name, req.Prefix) {
+				completions = append(completions, info)
+			}
+		}
+		c.send(Response{ID: req.ID, Status: "done", Completions: completions})
+
+	default:
+		c.send(Response{ID: req.ID, Status: "error", Error: fmt.Sprintf("unknown op %q, use eval, complete or interrupt", req.Op)})
+	}
+}
+
+// cancel stops the eval with the given id, or every eval when id is empty,
+// and returns the ids it stopped
+func (c *client) cancel(id string) []string {
+	c.evalsMu.Lock()
+	defer c.evalsMu.Unlock()
+
+	var ids []string
+	for evalID, cancel := range c.evals {
+		if id == "" || evalID == id {
+			cancel()
+			ids = append(ids, evalID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (c *client) finish(id string) {
+	c.evalsMu.Lock()
+	defer c.evalsMu.Unlock()
+	if cancel, ok := c.evals[id]; ok {
+		cancel()
+		delete(c.evals, id)
+	}
+}
+
+// evalPlain evaluates a line of source and answers in plain text. Plain
+// lines are evaluated in the read loop since there is no way to interrupt
+// them other than closing the connection.
+func (c *client) evalPlain(source string) {
+	stack, output, err := c.session.Evaluate(context.Background(), source)
+	if err != nil {
+		output = append(output, "Error: "+err.Error())
+	}
+
+	var b strings.Builder
+	for _, line := range output {
+		fmt.Fprintln(&b, line)
+	}
+	fmt.Fprintf(&b, "ok %v\n", []forth.StackItem(stack))
+	c.write(b.String())
+}
+
+func (c *client) sendEvent(e connections.Event) {
+	if !c.plain.Load() {
+		c.send(e)
+		return
+	}
+
+	var b strings.Builder
+	for _, line := range e.Lines {
+		if e.Type == connections.EventError {
+			fmt.Fprintf(&b, "%s error: %s\n", e.Hed, line)
+		} else {
+			fmt.Fprintf(&b, "%s %s: %s\n", e.Hed, e.Nod, line)
+		}
+	}
+	c.write(b.String())
+}
+
+// send writes v as a line of JSON
+func (c *client) send(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Error encoding repl response: %v", err)
+		return
+	}
+	c.write(string(data) + "\n")
+}
+
+func (c *client) write(s string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := io.WriteString(c.conn, s); err != nil {
+		// The read loop notices the connection has gone
+		c.conn.Close()
+	}
+}
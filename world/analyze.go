@@ -0,0 +1,164 @@
+// world/analyze.go
+package world
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of problem Analyze looks for
+const (
+	IssueStaleLink       = "stale-link"       // A link to a nod that is no longer in the grid
+	IssueUnreachable     = "unreachable"      // A nod no hed can reach
+	IssueCycle           = "cycle"            // A chain that loops instead of ending
+	IssueLastUnreachable = "last-unreachable" // A hed or call whose last can't be reached from its first
+	IssueNoFirst         = "no-first"         // A hed with no nod to start from
+)
+
+// Issue is one problem found in the link graph
+type Issue struct {
+	Kind    string `json:"kind"`
+	ID      string `json:"id"`   // The hed or nod the issue is about
+	Type    string `json:"type"` // "hed" or "nod"
+	Message string `json:"message"`
+}
+
+// Analysis is a report on the link graph of the grid
+type Analysis struct {
+	Nods      int     `json:"nods"`
+	Heds      int     `json:"heds"`
+	Reachable int     `json:"reachable"` // Nods at least one hed can reach
+	Issues    []Issue `json:"issues"`
+}
+
+// Analyze checks the links between heds and nods for stale pointers,
+// unreachable nods, cycles and windows whose last nod can't be reached
+func (m *Memory2D) Analyze() Analysis {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	a := Analysis{Heds: len(m.heds), Issues: make([]Issue, 0)}
+	add := func(kind, typ, id, format string, args ...interface{}) {
+		a.Issues = append(a.Issues, Issue{Kind: kind, ID: id, Type: typ, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var nods []*Nod
+	for _, row := range m.mem {
+		for _, n := range row {
+			if n != nil {
+				nods = append(nods, n)
+			}
+		}
+	}
+	a.Nods = len(nods)
+
+	// Links into cells whose nod has been replaced or removed
+	stale := func(n *Nod) bool { return n != nil && !m.inGrid(n) }
+	for _, n := range nods {
+		if stale(n.next) {
+			add(IssueStaleLink, "nod", n.ID(), "nod %s links to a nod that was at %s but is no longer in the grid", n.ID(), n.next.ID())
+		}
+		if n.call != nil && stale(n.call.first) {
+			add(IssueStaleLink, "nod", n.ID(), "nod %s calls a chain starting at %s which is no longer in the grid", n.ID(), n.call.first.ID())
+		}
+		if n.call != nil && stale(n.call.last) {
+			add(IssueStaleLink, "nod", n.ID(), "nod %s calls a chain ending at %s which is no longer in the grid", n.ID(), n.call.last.ID())
+		}
+	}
+	for _, hed := range m.heds {
+		for _, link := range []struct {
+			name string
+			nod  *Nod
+		}{{"first", hed.first}, {"current", hed.current}, {"last", hed.last}} {
+			if stale(link.nod) {
+				add(IssueStaleLink, "hed", hed.ID(), "hed %s %s nod %s is no longer in the grid", hed.ID(), link.name, link.nod.ID())
+			}
+		}
+	}
+
+	// Everything a hed can reach through next links and subroutine calls
+	reached := make(map[*Nod]bool)
+	var visit func(n *Nod)
+	visit = func(n *Nod) {
+		for ; n != nil && !reached[n]; n = n.next {
+			reached[n] = true
+			if n.call != nil {
+				visit(n.call.first)
+			}
+		}
+	}
+	for _, hed := range m.heds {
+		if hed.first == nil {
+			add(IssueNoFirst, "hed", hed.ID(), "hed %s has no first nod", hed.ID())
+			continue
+		}
+		visit(hed.first)
+		visit(hed.current)
+
+		if hed.last != nil && !onChain(hed.first, hed.last) {
+			add(IssueLastUnreachable, "hed", hed.ID(), "hed %s last nod %s can't be reached from its first nod %s, so it never wraps there",
+				hed.ID(), hed.last.ID(), hed.first.ID())
+		}
+	}
+	for _, n := range nods {
+		if reached[n] {
+			a.Reachable++
+		} else {
+			add(IssueUnreachable, "nod", n.ID(), "nod %s can't be reached by any hed", n.ID())
+		}
+		if n.call != nil && n.call.first != nil && n.call.last != nil && !onChain(n.call.first, n.call.last) {
+			add(IssueLastUnreachable, "nod", n.ID(), "nod %s calls a chain whose last nod %s can't be reached from %s",
+				n.ID(), n.call.last.ID(), n.call.first.ID())
+		}
+	}
+
+	// Each nod has at most one next so every cycle is found by walking from
+	// each nod until the walk ends or comes back on itself
+	done := make(map[*Nod]bool)
+	for _, start := range nods {
+		onWalk := make(map[*Nod]int)
+		var walk []*Nod
+		n := start
+		for n != nil && !done[n] {
+			if _, ok := onWalk[n]; ok {
+				loop := walk[onWalk[n]:]
+				ids := make([]string, len(loop))
+				for i, l := range loop {
+					ids[i] = l.ID()
+				}
+				add(IssueCycle, "nod", n.ID(), "nods %s -> %s loop forever, heds on them never wrap", strings.Join(ids, " -> "), n.ID())
+				break
+			}
+			onWalk[n] = len(walk)
+			walk = append(walk, n)
+			n = n.next
+		}
+		for _, w := range walk {
+			done[w] = true
+		}
+	}
+
+	return a
+}
+
+// inGrid reports whether a nod is still the one stored at its position, the
+// caller holds m.mu
+func (m *Memory2D) inGrid(n *Nod) bool {
+	x, y := ParseID(n.ID())
+	if m.checkBounds(x, y) != nil {
+		return false
+	}
+	return m.mem[y][x] == n
+}
+
+// onChain reports whether target can be reached from start by next links
+func onChain(start, target *Nod) bool {
+	seen := make(map[*Nod]bool)
+	for n := start; n != nil && !seen[n]; n = n.next {
+		if n == target {
+			return true
+		}
+		seen[n] = true
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+// world/chain.go
+package world
+
+import (
+	"fmt"
+	"math/rand"
+
+	"3body/forth"
+)
+
+// Chain returns the nods linked from the nod at x y, stopping when the chain
+// runs out or loops back on itself
+func (m *Memory2D) Chain(x, y int) ([]*Nod, error) {
+	first, err := m.GetNod(x, y)
+	if err != nil {
+		return nil, err
+	}
+	return chainFrom(first), nil
+}
+
+// chainFrom walks next links from first
+func chainFrom(first *Nod) []*Nod {
+	var chain []*Nod
+	seen := make(map[*Nod]bool)
+	for n := first; n != nil && !seen[n]; n = n.next {
+		seen[n] = true
+		chain = append(chain, n)
+	}
+	return chain
+}
+
+// The chain transforms below only rewrite messages. Links, ticks and heds
+// are left alone so a running hed carries on through the new messages.
+
+// ReverseChain reverses the order of the messages in a chain
+func ReverseChain(chain []*Nod) {
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i].message, chain[j].message = chain[j].message, chain[i].message
+	}
+}
+
+// RotateChain moves every message n steps further along the chain, negative
+// n moves them back
+func RotateChain(chain []*Nod, n int) {
+	if len(chain) == 0 {
+		return
+	}
+	messages := chainMessages(chain)
+	for i := range chain {
+		j := ((i-n)%len(chain) + len(chain)) % len(chain)
+		chain[i].message = messages[j]
+	}
+}
+
+// ShuffleChain puts the messages of a chain in a random order
+func ShuffleChain(chain []*Nod) {
+	rand.Shuffle(len(chain), func(i, j int) {
+		chain[i].message, chain[j].message = chain[j].message, chain[i].message
+	})
+}
+
+// ScrambleChain shuffles the messages of a random percentage of the chain's
+// nods among themselves, leaving the rest where they are
+func ScrambleChain(chain []*Nod, percent float64) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("percentage must be between 0 and 100, got %g", percent)
+	}
+
+	picked := rand.Perm(len(chain))[:int(float64(len(chain))*percent/100+0.5)]
+	rand.Shuffle(len(picked), func(i, j int) {
+		a, b := chain[picked[i]], chain[picked[j]]
+		a.message, b.message = b.message, a.message
+	})
+	return nil
+}
+
+// MutateChain replaces each message with a random one of choices with
+// probability p
+func MutateChain(chain []*Nod, choices []Message, p float64) error {
+	if p < 0 || p > 1 {
+		return fmt.Errorf("probability must be between 0 and 1, got %g", p)
+	}
+	if len(choices) == 0 {
+		return fmt.Errorf("no messages to mutate to")
+	}
+
+	for _, n := range chain {
+		if rand.Float64() < p {
+			n.message = choices[rand.Intn(len(choices))]
+		}
+	}
+	return nil
+}
+
+func chainMessages(chain []*Nod) []Message {
+	messages := make([]Message, len(chain))
+	for i, n := range chain {
+		messages[i] = n.message
+	}
+	return messages
+}
+
+// itemMessage turns a stack item into a nod message
+func itemMessage(item forth.StackItem) Message {
+	switch v := item.(type) {
+	case string:
+		return Message(v)
+	case float64:
+		return Message(fmt.Sprintf("%g", v))
+	case int:
+		return Message(fmt.Sprintf("%d", v))
+	default:
+		return Message(fmt.Sprintf("%v", v))
+	}
+}
@@ -0,0 +1,107 @@
+// world/chainDictionary.go
+package world
+
+import (
+	"fmt"
+
+	"3body/forth"
+)
+
+// DefineChainDictionary creates forth words that rewrite the messages of a
+// whole chain in place. Each word takes the first nod of the chain, as y x
+// or a nod name, and leaves it on the stack.
+func DefineChainDictionary(memory *Memory2D) map[string]forth.DictionaryWord {
+	return map[string]forth.DictionaryWord{
+		// chain-reverse ( y x -- y x ) reverses the order of a chain's messages
+		"chain-reverse": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			nod, ref, newStack, err := popNod(memory, stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting nod: %v", err)}
+			}
+
+			ReverseChain(chainFrom(nod))
+
+			return append(newStack, ref...), state, nil
+		},
+
+		// chain-rotate ( y x n -- y x ) moves every message n steps along the
+		// chain, wrapping from the end back to the start
+		"chain-rotate": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			n, newStack, err := forth.PopInt(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			nod, ref, newStack, err := popNod(memory, newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting nod: %v", err)}
+			}
+
+			RotateChain(chainFrom(nod), n)
+
+			return append(newStack, ref...), state, nil
+		},
+
+		// chain-shuffle ( y x -- y x ) puts a chain's messages in a random order
+		"chain-shuffle": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			nod, ref, newStack, err := popNod(memory, stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting nod: %v", err)}
+			}
+
+			ShuffleChain(chainFrom(nod))
+
+			return append(newStack, ref...), state, nil
+		},
+
+		// chain-scramble ( y x percent -- y x ) shuffles the messages of a
+		// random percent of the chain's nods
+		"chain-scramble": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			percent, newStack, err := forth.PopFloat(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			nod, ref, newStack, err := popNod(memory, newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting nod: %v", err)}
+			}
+
+			if err := ScrambleChain(chainFrom(nod), percent); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return append(newStack, ref...), state, nil
+		},
+
+		// chain-mutate ( y x [ messages ] p -- y x ) replaces each message with
+		// one picked from the array with probability p
+		"chain-mutate": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			p, newStack, err := forth.PopFloat(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			arr, newStack, err := forth.PopArray(newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			choices := make([]Message, len(arr))
+			for i, item := range arr {
+				choices[i] = itemMessage(item)
+			}
+
+			nod, ref, newStack, err := popNod(memory, newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting nod: %v", err)}
+			}
+
+			if err := MutateChain(chainFrom(nod), choices, p); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return append(newStack, ref...), state, nil
+		},
+	}
+}
@@ -9,11 +9,12 @@ import (
 
 // Clock manages timing for the world
 type Clock struct {
-	interval time.Duration
-	memory   *Memory2D
-	running  bool
-	stopChan chan struct{}
-	mu       sync.Mutex
+	interval  time.Duration
+	memory    *Memory2D
+	running   bool
+	simulated bool // Ticks only when Advance is called
+	stopChan  chan struct{}
+	mu        sync.Mutex
 }
 
 // NewClock creates a new clock with the specified interval in milliseconds
@@ -24,6 +25,12 @@ func NewClock(intervalMs int) *Clock {
 	}
 }
 
+// NewSimulatedClock creates a clock that ignores the time and only ticks
+// when Advance is called, for running the world faster than real time
+func NewSimulatedClock() *Clock {
+	return &Clock{simulated: true, stopChan: make(chan struct{})}
+}
+
 // Start begins the clock with the given memory
 func (c *Clock) Start(memory *Memory2D) error {
 	c.mu.Lock()
@@ -38,7 +45,9 @@ func (c *Clock) Start(memory *Memory2D) error {
 	c.stopChan = make(chan struct{})
 
 	// Start the clock in a separate goroutine
-	go c.run()
+	if !c.simulated {
+		go c.run()
+	}
 
 	return nil
 }
@@ -80,6 +89,20 @@ func (c *Clock) run() {
 	}
 }
 
+// Advance bangs a simulated clock's memory once, unless the clock has been
+// stopped
+func (c *Clock) Advance() []error {
+	c.mu.Lock()
+	memory := c.memory
+	if !c.simulated || !c.running || memory == nil {
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+
+	return memory.Bang()
+}
+
 // IsRunning returns whether the clock is currently running
 func (c *Clock) IsRunning() bool {
 	c.mu.Lock()
@@ -0,0 +1,41 @@
+// world/docs.go
+package world
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+
+	"3body/forth"
+)
+
+//go:embed *Dictionary.go
+var dictionarySources embed.FS
+
+// Docs documents the words defined in this package from the comment on each
+// word. Each dictionary file is a category named after it, so the words in
+// chainDictionary.go are chain words.
+func Docs() (forth.WordDocs, error) {
+	files, err := dictionarySources.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make(forth.WordDocs)
+	for _, file := range files {
+		src, err := dictionarySources.ReadFile(file.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		category := strings.TrimSuffix(file.Name(), "Dictionary.go")
+		fileDocs, err := forth.DocsFromSource(category, src)
+		if err != nil {
+			return nil, fmt.Errorf("reading word docs from %s: %w", file.Name(), err)
+		}
+		for name, info := range fileDocs {
+			docs[name] = info
+		}
+	}
+	return docs, nil
+}
@@ -0,0 +1,128 @@
+// world/eventDictionary.go
+package world
+
+import (
+	"fmt"
+
+	"3body/forth"
+)
+
+// DefineEventDictionary creates forth words for triggering heds and for
+// global events
+func DefineEventDictionary(memory *Memory2D) map[string]forth.DictionaryWord {
+	return map[string]forth.DictionaryWord{
+		// hed-triggered ( y x|'name -- y x|'name ) makes a hed, or every hed in a
+		// region, ignore the clock and only move when triggered
+		"hed-triggered": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			heds, ref, newStack, err := popHeds(memory, stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting head: %v", err)}
+			}
+
+			for _, hed := range heds {
+				hed.SetTriggered(true)
+			}
+
+			return append(newStack, ref...), state, nil
+		},
+
+		// hed-clocked ( y x|'name -- y x|'name ) returns triggered heds to the clock
+		"hed-clocked": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			heds, ref, newStack, err := popHeds(memory, stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting head: %v", err)}
+			}
+
+			for _, hed := range heds {
+				hed.SetTriggered(false)
+			}
+
+			return append(newStack, ref...), state, nil
+		},
+
+		// trigger ( y x|'name -- ) fires a hed's current nod and steps it once.
+		// From a nod message it fires straight away, from the editor on the
+		// next bang so it doesn't race the clock.
+		"trigger": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			heds, _, newStack, err := popHeds(memory, stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting head: %v", err)}
+			}
+
+			for _, hed := range heds {
+				if state.Context == nil {
+					memory.QueueTrigger(hed)
+					continue
+				}
+				if err := hed.Trigger(state.Context.Tick); err != nil {
+					return stack, state, []string{fmt.Sprintf("Error: head %s: %v", hed.ID(), err)}
+				}
+			}
+
+			return newStack, state, nil
+		},
+
+		// play-once ( y x|'name -- ) plays a triggered hed's sequence once on the clock
+		"play-once": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			heds, _, newStack, err := popHeds(memory, stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting head: %v", err)}
+			}
+
+			for _, hed := range heds {
+				hed.PlayOnce()
+			}
+
+			return newStack, state, nil
+		},
+
+		// on ( "name" { block } -- ) runs block whenever name is emitted
+		"on": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if len(stack) < 2 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			s, item, _ := forth.Pop(stack)
+			block, ok := item.(forth.QuotedBlock)
+			if !ok {
+				return stack, state, []string{"Error: top item is not a quoted block"}
+			}
+
+			name, s, err := forth.PopString(s)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			memory.On(name, block.Source(), state)
+
+			return s, state, nil
+		},
+
+		// off ( "name" -- ) removes every handler for an event
+		"off": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			name, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			memory.Off(name)
+
+			return newStack, state, nil
+		},
+
+		// emit ( "name" -- ) runs the handlers for an event
+		"emit": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			name, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			output, err := memory.Emit(name, state.Context)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return newStack, state, output
+		},
+	}
+}
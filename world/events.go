@@ -0,0 +1,75 @@
+// world/events.go
+package world
+
+import (
+	"fmt"
+	"sync"
+
+	"3body/forth"
+)
+
+// maxEventDepth stops handlers that emit events from looping forever
+const maxEventDepth = 16
+
+// eventHandler is forth source registered with on, along with the state it
+// was registered from so it can use words defined at that point
+type eventHandler struct {
+	source string
+	state  forth.State
+}
+
+// eventBus holds handlers for named events
+type eventBus struct {
+	handlers map[string][]eventHandler
+	depth    int
+	mu       sync.Mutex
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{handlers: make(map[string][]eventHandler)}
+}
+
+// On registers forth source to run whenever name is emitted
+func (m *Memory2D) On(name string, source string, state forth.State) {
+	b := m.events
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[name] = append(b.handlers[name], eventHandler{source: source, state: state})
+}
+
+// Off removes every handler for name
+func (m *Memory2D) Off(name string) {
+	b := m.events
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.handlers, name)
+}
+
+// Emit runs the handlers for name in the order they were registered. Each
+// handler starts with an empty stack and sees the emitter's context.
+func (m *Memory2D) Emit(name string, ctx *forth.EvalContext) ([]string, error) {
+	b := m.events
+	b.mu.Lock()
+	if b.depth >= maxEventDepth {
+		b.mu.Unlock()
+		return nil, fmt.Errorf("event %s nested more than %d deep", name, maxEventDepth)
+	}
+	handlers := append([]eventHandler(nil), b.handlers[name]...)
+	b.depth++
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		b.depth--
+		b.mu.Unlock()
+	}()
+
+	var output []string
+	for _, h := range handlers {
+		state := h.state
+		state.Context = ctx
+		_, _, out := forth.Interpret(h.source, forth.CreateStack(), state)
+		output = append(output, out...)
+	}
+	return output, nil
+}
@@ -2,19 +2,38 @@
 package world
 
 import (
+	"3body/connections"
 	"3body/forth"
 	"fmt"
 )
 
+// maxCallDepth limits how deeply subroutine nods can nest
+const maxCallDepth = 8
+
+// callFrame remembers where a hed descended into a subroutine
+type callFrame struct {
+	site *Nod // The subroutine nod the hed returns past
+	last *Nod // The last nod of the subroutine, nil runs until next is nil
+}
+
 // Hed represents a head that moves through the nodes
 type Hed struct {
 	id         string
-	first      *Nod // Points to first node in sequence
-	current    *Nod // Current node in sequence
-	last       *Nod // The last nod in a sequence, used for windowed nods, this is optional
-	every      int  // How often to trigger
-	bangs      int  // Count of bangs received
-	stopped    bool // Whether head is stopped
+	name       string      // Optional symbolic name
+	first      *Nod        // Points to first node in sequence
+	current    *Nod        // Current node in sequence
+	last       *Nod        // The last nod in a sequence, used for windowed nods, this is optional
+	every      int         // How often to trigger
+	bangs      int         // Count of bangs received
+	wait       int         // Ticks left before the next nod when the last nod set its own ticks
+	fires      int         // Count of nods fired
+	cycle      int         // Count of wraps back to the first nod
+	step       int         // Position of the current nod counted from first
+	stopped    bool        // Whether head is stopped
+	triggered  bool        // Whether head ignores the clock and only moves when triggered
+	playing    bool        // Whether a triggered head is playing its sequence once on the clock
+	firing     bool        // Guards against a nod message triggering its own head
+	calls      []callFrame // Subroutines the head is currently inside
 	stack      forth.Stack
 	forthState forth.State
 	modifier   string // appended to the end of a message before execution
@@ -40,39 +59,157 @@ func NewHed(id string, first *Nod, last *Nod, every int, modifier string, state
 }
 
 // Bang processes a tick for this head
-func (h *Hed) Bang() error {
-	if h.stopped {
+func (h *Hed) Bang(tick int) error {
+	if h.stopped || (h.triggered && !h.playing) {
 		return nil
 	}
 
 	h.bangs++
-	if h.bangs%h.every == 0 {
-		if h.current == nil {
-			return fmt.Errorf("current node is nil")
+
+	// Nods with their own ticks override every until the next nod fires
+	if h.wait > 0 {
+		h.wait--
+		if h.wait > 0 {
+			return nil
 		}
+	} else if h.bangs%h.every != 0 {
+		return nil
+	}
+
+	if err := h.fire(tick); err != nil {
+		return err
+	}
+
+	// A one shot play ends once the head has wrapped back to its first nod
+	if h.playing && h.step == 0 {
+		h.playing = false
+	}
+	return nil
+}
+
+// Trigger fires the current nod immediately and moves on, regardless of
+// the clock. Stopped heds ignore triggers.
+func (h *Hed) Trigger(tick int) error {
+	if h.stopped {
+		return nil
+	}
+	return h.fire(tick)
+}
+
+// PlayOnce sends a triggered head back to its first nod and lets the clock
+// play the sequence through once
+func (h *Hed) PlayOnce() {
+	h.Restart()
+	h.playing = true
+}
+
+// SetTriggered switches the head between clock driven and trigger driven
+func (h *Hed) SetTriggered(triggered bool) {
+	h.triggered = triggered
+	h.playing = false
+}
+
+// fire evaluates the current nod and advances to the next one
+func (h *Hed) fire(tick int) error {
+	if h.firing {
+		return fmt.Errorf("hed %s triggered itself while firing", h.id)
+	}
+	h.firing = true
+	defer func() { h.firing = false }()
+
+	if h.current == nil {
+		return fmt.Errorf("current node is nil")
+	}
 
-		// Process current node
-		newStack, newState, _, err := h.current.Bang(h.stack, h.forthState, h.modifier)
-		if err != nil {
-			return fmt.Errorf("error processing node: %w", err)
+	// Subroutine nods don't fire themselves, the hed descends into the called
+	// chain and fires its first nod on the same tick
+	site, depth := h.current, len(h.calls)
+	for h.current.call != nil {
+		if len(h.calls) >= maxCallDepth {
+			h.current, h.calls = site, h.calls[:depth]
+			return fmt.Errorf("subroutine calls nested more than %d deep", maxCallDepth)
 		}
+		if h.current.call.first == nil {
+			id := h.current.ID()
+			h.current, h.calls = site, h.calls[:depth]
+			return fmt.Errorf("subroutine at %s has no first nod", id)
+		}
+		h.calls = append(h.calls, callFrame{site: h.current, last: h.current.call.last})
+		h.current = h.current.call.first
+	}
+
+	h.fires++
+	x, y := ParseID(h.current.ID())
+	ctx := &forth.EvalContext{
+		HedID: h.id,
+		NodID: h.current.ID(),
+		NodX:  x,
+		NodY:  y,
+		Bangs: h.fires,
+		Cycle: h.cycle,
+		Step:  h.step,
+		Tick:  tick,
+	}
 
-		h.stack = newStack
-		h.forthState = newState
+	// Process current node
+	newStack, newState, output, err := h.current.Bang(h.stack, h.forthState, h.modifier, ctx)
+	if len(output) > 0 {
+		connections.Publish(connections.Event{Type: connections.EventOutput, Hed: h.id, Nod: ctx.NodID, Tick: tick, Lines: output})
+	}
+	if forth.IsLimitError(err) {
+		// A runaway message is abandoned along with whatever the hed's
+		// stack had built up, and the hed carries on to the next nod
+		h.stack = forth.CreateStack()
+		h.advance()
+		return fmt.Errorf("nod %s stopped: %w", ctx.NodID, err)
+	}
+	if err != nil {
+		return fmt.Errorf("error processing node: %w", err)
+	}
 
-		// Move to next node or wrap around to first
-		if h.last != nil && h.current.id == h.last.id {
-			// If we have a last node and we're at it, wrap to first
-			h.current = h.first
-		} else if h.current.Next() != nil {
-			// If we have a next node, move to it
+	h.stack = newStack
+	h.forthState = newState
+	h.wait = h.current.Ticks()
+
+	h.advance()
+	return nil
+}
+
+// advance moves to the next nod, returning from finished subroutines
+func (h *Hed) advance() {
+	for len(h.calls) > 0 {
+		frame := h.calls[len(h.calls)-1]
+		if (frame.last == nil || h.current.id != frame.last.id) && h.current.Next() != nil {
 			h.current = h.current.Next()
-		} else {
-			// If no next node or last specified, wrap to first
-			h.current = h.first
+			h.step++
+			return
 		}
+
+		// The subroutine has ended, carry on from the nod after the call
+		h.calls = h.calls[:len(h.calls)-1]
+		h.current = frame.site
 	}
-	return nil
+
+	// Move to next node or wrap around to first
+	if h.last != nil && h.current.id == h.last.id {
+		// If we have a last node and we're at it, wrap to first
+		h.wrap()
+	} else if h.current.Next() != nil {
+		// If we have a next node, move to it
+		h.current = h.current.Next()
+		h.step++
+	} else {
+		// If no next node or last specified, wrap to first
+		h.wrap()
+	}
+}
+
+// wrap moves the head back to its first nod and starts a new cycle
+func (h *Hed) wrap() {
+	h.current = h.first
+	h.calls = nil
+	h.step = 0
+	h.cycle++
 }
 
 // Start begins head movement
@@ -85,6 +222,20 @@ func (h *Hed) Stop() {
 	h.stopped = true
 }
 
+// Prime makes the head fire on its next bang and every ticks after that
+func (h *Hed) Prime() {
+	h.bangs = h.every - 1
+	h.wait = 0
+}
+
+// Restart moves the head back to its first nod and primes it
+func (h *Hed) Restart() {
+	h.current = h.first
+	h.calls = nil
+	h.step = 0
+	h.Prime()
+}
+
 // SetEvery updates the frequency
 func (h *Hed) SetEvery(every int) {
 	h.every = every
@@ -95,11 +246,22 @@ func (h *Hed) SetModifier(modifier string) {
 	h.modifier = modifier
 }
 
+// SetKey sets the key and scale used by degree words in this head's messages
+func (h *Hed) SetKey(root float64, scale string) {
+	h.forthState.Key = root
+	h.forthState.Scale = scale
+}
+
 // ID returns the head's identifier
 func (h *Hed) ID() string {
 	return h.id
 }
 
+// Name returns the head's symbolic name, "" if it has none
+func (h *Hed) Name() string {
+	return h.name
+}
+
 func (h *Hed) CurrentNod() *Nod {
 	return h.current
 }
@@ -10,6 +10,7 @@ import (
 func DefineHedDictionary(memory *Memory2D) map[string]forth.DictionaryWord {
 	return map[string]forth.DictionaryWord{
 
+		// hed-new ( y x -- y x ) creates a hed with no nods
 		"hed-new": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			if len(stack) < 2 {
 				return stack, state, []string{"Error: stack underflow"}
@@ -53,138 +54,108 @@ func DefineHedDictionary(memory *Memory2D) map[string]forth.DictionaryWord {
 			return stack, state, nil
 		},
 
+		// hed-first ( y x|'name nodY nodX|'nod -- y x|'name ) sets the nod a
+		// hed starts from and moves the hed there
 		"hed-first": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
-			if len(stack) < 4 {
-				return stack, state, []string{"Error: stack underflow"}
-			}
-
-			firstX, newStack, err := forth.PopInt(stack)
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
-			}
-			stack = newStack
-
-			firstY, newStack, err := forth.PopInt(stack)
+			nod, _, newStack, err := popNod(memory, stack)
 			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
-			}
-			stack = newStack
-
-			hedX, newStack, err := forth.PopInt(stack)
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
-			}
-			stack = newStack
-
-			hedY, newStack, err := forth.PopInt(stack)
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+				return stack, state, []string{fmt.Sprintf("Error fetching nod: %v", err)}
 			}
-			stack = newStack
 
-			hed, err := memory.GetHed(hedX, hedY)
+			hed, ref, newStack, err := popHed(memory, newStack)
 			if err != nil {
 				return stack, state, []string{fmt.Sprintf("Error fetching hed: %v", err)}
 			}
 
-			nod, err := memory.GetNod(firstX, firstY)
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error fetching nod: %v", err)}
-			}
-
 			hed.first = nod
 			hed.current = nod // Maybe this should be taken care of by the hed structure?
+			hed.calls = nil
 
-			stack = append(stack, int(hedY))
-			stack = append(stack, int(hedX))
-			return stack, state, nil
+			return append(newStack, ref...), state, nil
 		},
 
+		// hed-last ( y x|'name nodY nodX|'nod -- y x|'name ) sets the nod a
+		// hed wraps back to its first nod after
 		"hed-last": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
-			if len(stack) < 4 {
-				return stack, state, []string{"Error: stack underflow"}
-			}
-
-			lastX, newStack, err := forth.PopInt(stack)
+			nod, _, newStack, err := popNod(memory, stack)
 			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+				return stack, state, []string{fmt.Sprintf("Error fetching nod: %v", err)}
 			}
-			stack = newStack
 
-			lastY, newStack, err := forth.PopInt(stack)
+			hed, ref, newStack, err := popHed(memory, newStack)
 			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+				return stack, state, []string{fmt.Sprintf("Error fetching hed: %v", err)}
 			}
-			stack = newStack
 
-			hedX, newStack, err := forth.PopInt(stack)
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
-			}
-			stack = newStack
+			hed.last = nod
 
-			hedY, newStack, err := forth.PopInt(stack)
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
-			}
-			stack = newStack
+			return append(newStack, ref...), state, nil
+		},
 
-			hed, err := memory.GetHed(hedX, hedY)
+		// hed-wrap ( y x|'name "wrapper" -- y x|'name ) sets the modifier
+		// appended to each message the hed fires
+		"hed-wrap": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			wrapper, newStack, err := forth.PopString(stack)
 			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error fetching hed: %v", err)}
+				return stack, state, []string{fmt.Sprintf("Error popping wrapper: %v", err)}
 			}
 
-			nod, err := memory.GetNod(lastX, lastY)
+			hed, ref, newStack, err := popHed(memory, newStack)
 			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error fetching nod: %v", err)}
+				return stack, state, []string{fmt.Sprintf("Error getting hed: %v", err)}
 			}
 
-			hed.last = nod
+			hed.modifier = wrapper
 
-			stack = append(stack, int(hedY))
-			stack = append(stack, int(hedX))
-			return stack, state, nil
+			return append(newStack, ref...), state, nil
 		},
 
-		"hed-wrap": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
-			if len(stack) < 3 {
-				return stack, state, []string{"Error: stack underflow"}
+		// hed-name ( y x|'name "name" -- y x|'name ) names a hed so it can be
+		// addressed without coordinates
+		"hed-name": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			name, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
 			}
 
-			wrapper, newStack, err := forth.PopString(stack)
+			hed, ref, newStack, err := popHed(memory, newStack)
 			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error popping wrapper: %v", err)}
+				return stack, state, []string{fmt.Sprintf("Error getting hed: %v", err)}
 			}
-			stack = newStack
 
-			hedX, newStack, err := forth.PopInt(stack)
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error popping hedX: %v", err)}
+			if err := memory.NameHed(hed, name); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
 			}
-			stack = newStack
 
-			hedY, newStack, err := forth.PopInt(stack)
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error popping hedY: %v", err)}
+			return append(newStack, ref...), state, nil
+		},
+
+		// hed-remove ( y x|'name -- ) deletes a hed, or every hed in a region
+		"hed-remove": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if errs := refuseOnClock("hed-remove", state); errs != nil {
+				return stack, state, errs
 			}
-			stack = newStack
 
-			hed, err := memory.GetHed(hedX, hedY)
+			heds, _, newStack, err := popHeds(memory, stack)
 			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error getting hed: %v", err)}
+				return stack, state, []string{fmt.Sprintf("Error getting head: %v", err)}
 			}
 
-			hed.modifier = wrapper
+			for _, hed := range heds {
+				x, y := ParseID(hed.ID())
+				if err := memory.RemoveHed(x, y); err != nil {
+					return stack, state, []string{fmt.Sprintf("Error removing head: %v", err)}
+				}
+			}
 
-			stack = append(stack, int(hedY))
-			stack = append(stack, int(hedX))
-			return stack, state, nil
+			return newStack, state, nil
 		},
 
 		// BELOW ARE LEGACY WORDS SORT THROUGH, RENAME, DISCARD
 		// If you use any of them in the next couple of weeks then port them to use the above words
 
-		// ( nodY nodX destY destX every -- nedY hedX ) creates a new hed with default values
+		// hed ( nodY nodX destY destX every -- hedY hedX ) creates a hed at
+		// destY destX starting from the nod at nodY nodX
 		"hed": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			if len(stack) < 5 {
 				return stack, state, []string{"Error: stack underflow"}
@@ -251,47 +222,30 @@ func DefineHedDictionary(memory *Memory2D) map[string]forth.DictionaryWord {
 			return stack, state, nil
 		},
 
-		// mod ( y x modMessage -- y x ) adds a modifier to a head
+		// mod ( y x|'name modMessage -- y x|'name ) adds a modifier to heds
 		"mod": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
-			if len(stack) < 3 {
-				return stack, state, []string{"Error: stack underflow"}
-			}
-
 			modMsg, newStack, err := forth.PopString(stack)
 			if err != nil {
 				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
 			}
-			stack = newStack
-
-			x, newStack, err := forth.PopInt(stack)
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
-			}
-			stack = newStack
-
-			y, newStack, err := forth.PopInt(stack)
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
-			}
-			stack = newStack
 
-			hed, err := memory.GetHed(int(x), int(y))
+			heds, ref, newStack, err := popHeds(memory, newStack)
 			if err != nil {
 				return stack, state, []string{fmt.Sprintf("Error getting head: %v", err)}
 			}
 
-			if modMsg == "0" {
-				hed.SetModifier("")
-			} else {
-				hed.SetModifier(modMsg)
+			for _, hed := range heds {
+				if modMsg == "0" {
+					hed.SetModifier("")
+				} else {
+					hed.SetModifier(modMsg)
+				}
 			}
 
-			stack = append(stack, float64(y))
-			stack = append(stack, float64(x))
-
-			return stack, state, nil
+			return append(newStack, ref...), state, nil
 		},
-		// nodY nodX destY destX wrapperString every hed-wrapped
+		// hed-wrapped ( nodY nodX destY destX "wrapper" every -- hedY hedX )
+		// creates a hed like hed with a modifier appended to each message
 		"hed-wrapped": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			if len(stack) < 6 {
 				return stack, state, []string{"Error: stack underflow"}
@@ -364,7 +318,9 @@ func DefineHedDictionary(memory *Memory2D) map[string]forth.DictionaryWord {
 			return stack, state, nil
 		},
 
-		// destY destX firstY firstX lastY lastX address every
+		// hed-loop ( hedY hedX firstY firstX lastY lastX "address" every -- )
+		// creates a hed looping from first to last that sends each message
+		// to an osc address
 		"hed-loop": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			if len(stack) < 8 {
 				return stack, state, []string{"Error: stack underflow"}
@@ -452,147 +408,228 @@ func DefineHedDictionary(memory *Memory2D) map[string]forth.DictionaryWord {
 
 		},
 
+		// start ( y x|'name -- y x|'name ) starts a hed, or every hed in a region
 		"start": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
-			if len(stack) < 2 {
-				return stack, state, []string{"Error: stack underflow"}
+			heds, ref, newStack, err := popHeds(memory, stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting head: %v", err)}
 			}
 
-			x, newStack, err := forth.PopInt(stack)
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			for _, hed := range heds {
+				hed.Start()
 			}
-			stack = newStack
 
-			y, newStack, err := forth.PopInt(stack)
+			return append(newStack, ref...), state, nil
+		},
+
+		// stop ( y x|'name -- y x|'name ) stops a hed, or every hed in a region
+		"stop": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			heds, ref, newStack, err := popHeds(memory, stack)
 			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+				return stack, state, []string{fmt.Sprintf("Error getting head: %v", err)}
 			}
-			stack = newStack
 
-			hed, err := memory.GetHed(int(x), int(y))
+			for _, hed := range heds {
+				hed.Stop()
+			}
+
+			return append(newStack, ref...), state, nil
+		},
+
+		// start-q ( y x|'name -- y x|'name ) starts heds on the next launch boundary
+		"start-q": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			heds, ref, newStack, err := popHeds(memory, stack)
 			if err != nil {
 				return stack, state, []string{fmt.Sprintf("Error getting head: %v", err)}
 			}
 
-			hed.Start()
+			for _, hed := range heds {
+				if err := memory.QueueLaunch(hed, LaunchStart); err != nil {
+					return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+				}
+			}
 
-			stack = append(stack, float64(y))
-			stack = append(stack, float64(x))
-			return stack, state, nil
+			return append(newStack, ref...), state, nil
 		},
 
-		"stop": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
-			if len(stack) < 2 {
-				return stack, state, []string{"Error: stack underflow"}
+		// stop-q ( y x|'name -- y x|'name ) stops heds on the next launch boundary
+		"stop-q": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			heds, ref, newStack, err := popHeds(memory, stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting head: %v", err)}
 			}
 
-			x, newStack, err := forth.PopInt(stack)
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			for _, hed := range heds {
+				if err := memory.QueueLaunch(hed, LaunchStop); err != nil {
+					return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+				}
 			}
-			stack = newStack
 
-			y, newStack, err := forth.PopInt(stack)
+			return append(newStack, ref...), state, nil
+		},
+
+		// sync ( [ y x|'name ... ] -- ) sends a group of heds back to their
+		// first nod on the next launch boundary
+		"sync": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			refs, newStack, err := forth.PopArray(stack)
 			if err != nil {
 				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
 			}
-			stack = newStack
 
-			hed, err := memory.GetHed(int(x), int(y))
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error getting head: %v", err)}
+			// Resolve the array back to front so names and y x pairs can be mixed
+			var heds []*Hed
+			rest := make(forth.Stack, len(refs))
+			for i, item := range refs {
+				rest[i] = item
+			}
+			for len(rest) > 0 {
+				found, _, r, err := popHeds(memory, rest)
+				if err != nil {
+					return stack, state, []string{fmt.Sprintf("Error getting head: %v", err)}
+				}
+				heds = append(heds, found...)
+				rest = r
 			}
 
-			hed.Stop()
+			for _, hed := range heds {
+				if err := memory.QueueLaunch(hed, LaunchSync); err != nil {
+					return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+				}
+			}
 
-			stack = append(stack, float64(y))
-			stack = append(stack, float64(x))
-			return stack, state, nil
+			return newStack, state, nil
 		},
 
-		"point": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
-			if len(stack) < 4 {
+		// set-quantum ( ticks|'beat|'bar -- ) sets the launch boundary
+		"set-quantum": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if len(stack) < 1 {
 				return stack, state, []string{"Error: stack underflow"}
 			}
 
-			x2, newStack, err := forth.PopInt(stack)
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			s, item, _ := forth.Pop(stack)
+
+			var ticks int
+			switch v := item.(type) {
+			case float64:
+				ticks = int(v)
+			case int:
+				ticks = v
+			case string:
+				switch v {
+				case "beat":
+					ticks = memory.TicksPerBeat()
+				case "bar":
+					ticks = memory.TicksPerBeat() * defaultBeatsPerBar
+				default:
+					return stack, state, []string{fmt.Sprintf("Error: unknown quantum %q, use a tick count, beat or bar", v)}
+				}
+			default:
+				return stack, state, []string{fmt.Sprintf("Error: unknown quantum %v", item)}
 			}
-			stack = newStack
 
-			y2, newStack, err := forth.PopInt(stack)
-			if err != nil {
+			if err := memory.SetQuantum(ticks); err != nil {
 				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
 			}
-			stack = newStack
 
-			x1, newStack, err := forth.PopInt(stack)
+			return s, state, nil
+		},
+
+		// set-beat ( ticks -- ) sets how many ticks make a beat
+		"set-beat": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			ticks, newStack, err := forth.PopInt(stack)
 			if err != nil {
 				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
 			}
-			stack = newStack
 
-			y1, newStack, err := forth.PopInt(stack)
-			if err != nil {
+			if err := memory.SetTicksPerBeat(ticks); err != nil {
 				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
 			}
-			stack = newStack
 
-			nod, err := memory.GetNod(int(x1), int(y1))
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error getting nod: %v", err)}
+			return newStack, state, nil
+		},
+
+		// bang# ( -- n ) how many times the running hed has fired
+		"bang#": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if state.Context == nil {
+				return stack, state, []string{"Error: bang# is only available inside a hed"}
 			}
+			return forth.Push(stack, float64(state.Context.Bangs)), state, nil
+		},
 
-			nextNod, err := memory.GetNod(int(x2), int(y2))
-			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error getting nod: %v", err)}
+		// cycle# ( -- n ) how many times the running hed has wrapped
+		"cycle#": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if state.Context == nil {
+				return stack, state, []string{"Error: cycle# is only available inside a hed"}
 			}
+			return forth.Push(stack, float64(state.Context.Cycle)), state, nil
+		},
 
-			if x1 == x2 && y1 == y2 {
-				nod.SetNext(nil)
-			} else {
-				nod.SetNext(nextNod)
+		// step# ( -- n ) position of the current nod from the hed's first nod
+		"step#": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if state.Context == nil {
+				return stack, state, []string{"Error: step# is only available inside a hed"}
 			}
+			return forth.Push(stack, float64(state.Context.Step)), state, nil
+		},
 
-			stack = append(stack, float64(y2))
-			stack = append(stack, float64(x2))
+		// here ( -- y x ) coordinates of the nod being evaluated
+		"here": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if state.Context == nil {
+				return stack, state, []string{"Error: here is only available inside a hed"}
+			}
+			stack = forth.Push(stack, float64(state.Context.NodY))
+			stack = forth.Push(stack, float64(state.Context.NodX))
 			return stack, state, nil
 		},
 
-		"hed-freq": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
-			if len(stack) < 3 {
-				return stack, state, []string{"Error: stack underflow"}
+		// tick ( -- n ) the clock tick, outside a hed this is the latest tick
+		"tick": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if state.Context != nil {
+				return forth.Push(stack, float64(state.Context.Tick)), state, nil
 			}
+			return forth.Push(stack, float64(memory.Tick())), state, nil
+		},
 
-			freq, newStack, err := forth.PopFloat(stack)
+		// point ( y x nextY nextX -- nextY nextX ) links a nod to the next
+		// one, pointing a nod at itself unlinks it. Either may be a nod name.
+		"point": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			nextNod, ref, newStack, err := popNod(memory, stack)
 			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+				return stack, state, []string{fmt.Sprintf("Error getting nod: %v", err)}
 			}
-			stack = newStack
 
-			x, newStack, err := forth.PopInt(stack)
+			nod, _, newStack, err := popNod(memory, newStack)
 			if err != nil {
-				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+				return stack, state, []string{fmt.Sprintf("Error getting nod: %v", err)}
 			}
-			stack = newStack
 
-			y, newStack, err := forth.PopInt(stack)
+			if nod == nextNod {
+				nod.SetNext(nil)
+			} else {
+				nod.SetNext(nextNod)
+			}
+
+			return append(newStack, ref...), state, nil
+		},
+
+		// hed-freq ( y x|'name every -- y x|'name ) sets how many ticks
+		// pass between each nod a hed fires
+		"hed-freq": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			freq, newStack, err := forth.PopFloat(stack)
 			if err != nil {
 				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
 			}
-			stack = newStack
 
-			hed, err := memory.GetHed(int(x), int(y))
+			heds, ref, newStack, err := popHeds(memory, newStack)
 			if err != nil {
 				return stack, state, []string{fmt.Sprintf("Error getting head: %v", err)}
 			}
 
-			hed.SetEvery(int(freq))
+			for _, hed := range heds {
+				hed.SetEvery(int(freq))
+			}
 
-			stack = append(stack, float64(y))
-			stack = append(stack, float64(x))
-			return stack, state, nil
+			return append(newStack, ref...), state, nil
 		},
 	}
 }
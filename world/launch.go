@@ -0,0 +1,288 @@
+// world/launch.go
+package world
+
+import (
+	"fmt"
+	"sync"
+
+	"3body/forth"
+)
+
+// Launch actions that can be queued for the next quantum boundary
+const (
+	LaunchStart = "start"
+	LaunchStop  = "stop"
+	LaunchSync  = "sync"
+	LaunchScene = "scene"
+)
+
+// Actions that can't run where they are asked for, either because Bang holds
+// the grid or because they would race it. They are applied on the next bang
+// rather than waiting for a boundary.
+const (
+	LaunchRecall  = "recall"
+	LaunchBlend   = "blend"
+	LaunchTrigger = "trigger"
+)
+
+// Default timing used to resolve beat and bar quanta
+const (
+	defaultTicksPerBeat = 4
+	defaultBeatsPerBar  = 4
+)
+
+// pendingLaunch is an action waiting for the next boundary
+type pendingLaunch struct {
+	hed    *Hed
+	action string
+	scene  string      // Scene to recall for LaunchScene and LaunchRecall, or blend from
+	blend  string      // Scene to blend towards for LaunchBlend
+	p      float64     // Blend probability for LaunchBlend
+	state  forth.State // State for heds a scene has to recreate
+}
+
+// nextBang reports whether a launch is applied on the next bang regardless
+// of the quantum
+func (p pendingLaunch) nextBang() bool {
+	return p.action == LaunchRecall || p.action == LaunchBlend || p.action == LaunchTrigger
+}
+
+// launcher counts clock ticks and holds launches until the next boundary.
+// It has its own lock because words queue launches from inside nod messages
+// while Memory2D.mu is already held by Bang.
+type launcher struct {
+	tick         int
+	quantum      int
+	ticksPerBeat int
+	pending      []pendingLaunch
+	mu           sync.Mutex
+}
+
+func newLauncher() *launcher {
+	return &launcher{
+		quantum:      defaultTicksPerBeat * defaultBeatsPerBar,
+		ticksPerBeat: defaultTicksPerBeat,
+	}
+}
+
+// QueueLaunch schedules an action for a hed on the next quantum boundary.
+// Queuing start cancels a pending stop and vice versa.
+func (m *Memory2D) QueueLaunch(hed *Hed, action string) error {
+	switch action {
+	case LaunchStart, LaunchStop, LaunchSync:
+	default:
+		return fmt.Errorf("unknown launch action %q", action)
+	}
+
+	l := m.launcher
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	kept := l.pending[:0]
+	for _, p := range l.pending {
+		sameHed := p.hed == hed
+		replaced := p.action == action ||
+			(action == LaunchStart && p.action == LaunchStop) ||
+			(action == LaunchStop && p.action == LaunchStart)
+		if !(sameHed && replaced) {
+			kept = append(kept, p)
+		}
+	}
+	l.pending = append(kept, pendingLaunch{hed: hed, action: action})
+	return nil
+}
+
+// QueueScene schedules a scene recall for the next quantum boundary,
+// replacing any scene recall already waiting
+func (m *Memory2D) QueueScene(name string, state forth.State) error {
+	if _, err := m.Scene(name); err != nil {
+		return err
+	}
+
+	l := m.launcher
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	kept := l.pending[:0]
+	for _, p := range l.pending {
+		if p.action != LaunchScene {
+			kept = append(kept, p)
+		}
+	}
+	l.pending = append(kept, pendingLaunch{action: LaunchScene, scene: name, state: state})
+	return nil
+}
+
+// queueNextBang schedules an action for the next bang, for words that write
+// to the grid when they are used from a nod message
+func (m *Memory2D) queueNextBang(p pendingLaunch) {
+	l := m.launcher
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pending = append(l.pending, p)
+}
+
+// QueueRecall recalls a scene on the next bang, for recalls made from a nod
+// message while Bang holds the grid
+func (m *Memory2D) QueueRecall(name string, state forth.State) error {
+	if _, err := m.Scene(name); err != nil {
+		return err
+	}
+	m.queueNextBang(pendingLaunch{action: LaunchRecall, scene: name, state: state})
+	return nil
+}
+
+// QueueBlend blends two scenes on the next bang, for blends made from a nod
+// message while Bang holds the grid
+func (m *Memory2D) QueueBlend(a, b string, p float64) error {
+	for _, name := range []string{a, b} {
+		if _, err := m.Scene(name); err != nil {
+			return err
+		}
+	}
+	m.queueNextBang(pendingLaunch{action: LaunchBlend, scene: a, blend: b, p: p})
+	return nil
+}
+
+// QueueTrigger triggers a hed on the next bang, for triggers from the editor
+// that would otherwise fire the hed while the clock is moving it
+func (m *Memory2D) QueueTrigger(hed *Hed) {
+	m.queueNextBang(pendingLaunch{hed: hed, action: LaunchTrigger})
+}
+
+// SetQuantum sets the launch quantum in ticks
+func (m *Memory2D) SetQuantum(ticks int) error {
+	if ticks < 1 {
+		return fmt.Errorf("quantum must be at least one tick, got %d", ticks)
+	}
+	m.launcher.mu.Lock()
+	defer m.launcher.mu.Unlock()
+	m.launcher.quantum = ticks
+	return nil
+}
+
+// SetTicksPerBeat sets how many ticks make a beat for beat and bar quanta
+func (m *Memory2D) SetTicksPerBeat(ticks int) error {
+	if ticks < 1 {
+		return fmt.Errorf("ticks per beat must be at least one, got %d", ticks)
+	}
+	m.launcher.mu.Lock()
+	defer m.launcher.mu.Unlock()
+	m.launcher.ticksPerBeat = ticks
+	return nil
+}
+
+// TicksPerBeat returns how many ticks make a beat
+func (m *Memory2D) TicksPerBeat() int {
+	m.launcher.mu.Lock()
+	defer m.launcher.mu.Unlock()
+	return m.launcher.ticksPerBeat
+}
+
+// Tick returns the number of clock ticks processed so far
+func (m *Memory2D) Tick() int {
+	m.launcher.mu.Lock()
+	defer m.launcher.mu.Unlock()
+	return m.launcher.tick
+}
+
+// PendingLaunches returns the queued actions keyed by hed id
+func (m *Memory2D) PendingLaunches() map[string][]string {
+	m.launcher.mu.Lock()
+	defer m.launcher.mu.Unlock()
+
+	pending := make(map[string][]string)
+	for _, p := range m.launcher.pending {
+		if p.hed != nil {
+			pending[p.hed.ID()] = append(pending[p.hed.ID()], p.action)
+		}
+	}
+	return pending
+}
+
+// PendingScene returns the scene waiting for the next boundary, if any
+func (m *Memory2D) PendingScene() string {
+	m.launcher.mu.Lock()
+	defer m.launcher.mu.Unlock()
+
+	for _, p := range m.launcher.pending {
+		if p.action == LaunchScene {
+			return p.scene
+		}
+	}
+	return ""
+}
+
+// advanceLaunches applies pending launches if the current tick is a
+// boundary, along with those waiting for the next bang, then moves on to the
+// next tick. It returns the tick that was processed. Launches are taken off
+// the queue under the launcher lock and applied after it is released, since
+// a scene recall locks the grid.
+func (m *Memory2D) advanceLaunches() (int, []error) {
+	l := m.launcher
+	l.mu.Lock()
+	tick := l.tick
+	boundary := tick%l.quantum == 0
+	var due, kept []pendingLaunch
+	for _, p := range l.pending {
+		if boundary || p.nextBang() {
+			due = append(due, p)
+		} else {
+			kept = append(kept, p)
+		}
+	}
+	l.pending = kept
+	l.tick++
+	l.mu.Unlock()
+
+	// Scenes are recalled first so launches queued alongside them still apply
+	var errors []error
+	for _, p := range due {
+		var err error
+		switch p.action {
+		case LaunchScene:
+			err = m.RecallScene(p.scene, p.state, true)
+		case LaunchRecall:
+			err = m.RecallScene(p.scene, p.state, false)
+		case LaunchBlend:
+			err = m.BlendScenes(p.scene, p.blend, p.p)
+		default:
+			continue
+		}
+		if err != nil {
+			errors = append(errors, fmt.Errorf("scene %s error: %w", p.scene, err))
+		}
+	}
+
+	for _, p := range due {
+		switch p.action {
+		case LaunchStart:
+			p.hed.Prime()
+			p.hed.Start()
+		case LaunchStop:
+			p.hed.Stop()
+		case LaunchSync:
+			p.hed.Restart()
+		case LaunchTrigger:
+			if err := p.hed.Trigger(tick); err != nil {
+				errors = append(errors, fmt.Errorf("head %s error: %w", p.hed.ID(), err))
+			}
+		}
+	}
+	return tick, errors
+}
+
+// dropLaunches removes any launches queued for a hed
+func (m *Memory2D) dropLaunches(hed *Hed) {
+	l := m.launcher
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	kept := l.pending[:0]
+	for _, p := range l.pending {
+		if p.hed != hed {
+			kept = append(kept, p)
+		}
+	}
+	l.pending = kept
+}
@@ -0,0 +1,244 @@
+// world/layout.go
+package world
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Directions a sequence can be laid out in
+const (
+	LayoutRow      = "row"
+	LayoutColumn   = "column"
+	LayoutDiagonal = "diagonal"
+)
+
+// Ways a sequence can continue when it reaches the edge of the grid
+const (
+	FoldNone  = "none"
+	FoldWrap  = "wrap"  // Carry on from the start of the next row or column
+	FoldSnake = "snake" // Turn back along the next row or column
+)
+
+// What happens when a sequence is written over existing nods
+const (
+	OverwriteAllow  = "allow"
+	OverwriteReport = "report"
+	OverwriteReject = "reject"
+)
+
+// Layout describes how seq and the words built on it place nods
+type Layout struct {
+	Direction string
+	Fold      string
+	Overwrite string
+}
+
+// layoutSetting holds the layout used by seq. It has its own lock since
+// sequences are written from nod messages while Bang holds Memory2D.mu.
+type layoutSetting struct {
+	layout Layout
+	mu     sync.Mutex
+}
+
+func newLayoutSetting() *layoutSetting {
+	return &layoutSetting{layout: Layout{
+		Direction: LayoutRow,
+		Fold:      FoldNone,
+		Overwrite: OverwriteReport,
+	}}
+}
+
+// Layout returns the layout used by seq
+func (m *Memory2D) Layout() Layout {
+	m.layout.mu.Lock()
+	defer m.layout.mu.Unlock()
+	return m.layout.layout
+}
+
+// SetLayout changes the layout used by seq
+func (m *Memory2D) SetLayout(layout Layout) error {
+	switch layout.Direction {
+	case LayoutRow, LayoutColumn, LayoutDiagonal:
+	default:
+		return fmt.Errorf("unknown layout %q, use row, column or diagonal", layout.Direction)
+	}
+	switch layout.Fold {
+	case FoldNone, FoldWrap, FoldSnake:
+	default:
+		return fmt.Errorf("unknown fold %q, use none, wrap or snake", layout.Fold)
+	}
+	switch layout.Overwrite {
+	case OverwriteAllow, OverwriteReport, OverwriteReject:
+	default:
+		return fmt.Errorf("unknown overwrite mode %q, use allow, report or reject", layout.Overwrite)
+	}
+	if layout.Direction == LayoutDiagonal && layout.Fold != FoldNone {
+		return fmt.Errorf("diagonal layouts can't %s", layout.Fold)
+	}
+
+	m.layout.mu.Lock()
+	defer m.layout.mu.Unlock()
+	m.layout.layout = layout
+	return nil
+}
+
+// cell is a grid position
+type cell struct {
+	x, y int
+}
+
+// cells returns where n nods starting at x y go, or an error naming the
+// first cell that falls off the grid
+func (l Layout) cells(x, y, n, rows, cols int) ([]cell, error) {
+	cells := make([]cell, n)
+
+	// along is how far a row or column can run before it has to fold
+	along := cols - x
+	if l.Direction == LayoutColumn {
+		along = rows - y
+	}
+
+	for i := range cells {
+		line, pos := 0, i
+		if l.Fold != FoldNone && along > 0 {
+			line, pos = i/along, i%along
+			if l.Fold == FoldSnake && line%2 == 1 {
+				pos = along - 1 - pos
+			}
+		}
+
+		switch l.Direction {
+		case LayoutColumn:
+			cells[i] = cell{x + line, y + pos}
+		case LayoutDiagonal:
+			cells[i] = cell{x + pos, y + pos}
+		default:
+			cells[i] = cell{x + pos, y + line}
+		}
+
+		c := cells[i]
+		if c.y < 0 || c.y >= rows || c.x < 0 || c.x >= cols {
+			return nil, fmt.Errorf("sequence of %d nods laid out as %s from (%d,%d) leaves the grid at nod %d (%d,%d)",
+				n, l.Direction, x, y, i+1, c.x, c.y)
+		}
+	}
+	return cells, nil
+}
+
+// CheckSequence reports whether n nods laid out from x y would fit, and
+// would be allowed to overwrite whatever is already there
+func (m *Memory2D) CheckSequence(x, y, n int, layout Layout) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, _, err := m.checkSequence(x, y, n, layout)
+	return err
+}
+
+// PlaceSequence links nods into a chain and writes them to the grid laid out
+// from x y. Either every nod is written or none are. Nods written over
+// existing ones take over their links, names and heds, so rewriting a
+// running sequence changes what its heds play. It returns the ids of the
+// nods that were replaced.
+func (m *Memory2D) PlaceSequence(x, y int, nods []*Nod, layout Layout) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cells, occupied, err := m.checkSequence(x, y, len(nods), layout)
+	if err != nil {
+		return nil, err
+	}
+
+	replaced := make(map[*Nod]*Nod)
+	var ids []string
+	for _, i := range occupied {
+		c := cells[i]
+		replaced[m.mem[c.y][c.x]] = nods[i]
+		ids = append(ids, NodID(c.x, c.y))
+	}
+
+	for i, c := range cells {
+		nods[i].id = NodID(c.x, c.y)
+		if i < len(nods)-1 {
+			nods[i].next = nods[i+1]
+		}
+		m.mem[c.y][c.x] = nods[i]
+	}
+
+	if len(replaced) > 0 {
+		m.retarget(replaced)
+	}
+	return ids, nil
+}
+
+// checkSequence lays out n nods and finds which of them land on existing
+// nods, the caller holds m.mu
+func (m *Memory2D) checkSequence(x, y, n int, layout Layout) ([]cell, []int, error) {
+	cells, err := layout.cells(x, y, n, len(m.mem), len(m.mem[0]))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var occupied []int
+	var ids []string
+	for i, c := range cells {
+		if m.mem[c.y][c.x] != nil {
+			occupied = append(occupied, i)
+			ids = append(ids, NodID(c.x, c.y))
+		}
+	}
+	if len(ids) > 0 && layout.Overwrite == OverwriteReject {
+		return nil, nil, fmt.Errorf("sequence would overwrite nods at %s", strings.Join(ids, " "))
+	}
+	return cells, occupied, nil
+}
+
+// retarget points everything that referred to a replaced nod at its
+// replacement, the caller holds m.mu
+func (m *Memory2D) retarget(replaced map[*Nod]*Nod) {
+	swap := func(n *Nod) *Nod {
+		if r, ok := replaced[n]; ok {
+			return r
+		}
+		return n
+	}
+
+	for _, row := range m.mem {
+		for _, n := range row {
+			if n == nil {
+				continue
+			}
+			n.next = swap(n.next)
+			if n.call != nil {
+				n.call.first = swap(n.call.first)
+				n.call.last = swap(n.call.last)
+			}
+		}
+	}
+
+	for _, hed := range m.heds {
+		hed.first = swap(hed.first)
+		hed.current = swap(hed.current)
+		hed.last = swap(hed.last)
+		for i := range hed.calls {
+			hed.calls[i].site = swap(hed.calls[i].site)
+			hed.calls[i].last = swap(hed.calls[i].last)
+		}
+	}
+
+	for old, nod := range replaced {
+		if old.name != "" && nod.name == "" {
+			m.NameNod(nod, old.name)
+		}
+	}
+}
+
+// placeReport describes the nods a sequence replaced, if the layout asks
+// for that to be reported
+func placeReport(replaced []string, layout Layout) []string {
+	if len(replaced) == 0 || layout.Overwrite != OverwriteReport {
+		return nil
+	}
+	return []string{fmt.Sprintf("Replaced nods at %s", strings.Join(replaced, " "))}
+}
@@ -4,13 +4,22 @@ package world
 import (
 	"fmt"
 	"sync"
+
+	"3body/connections"
 )
 
 // Memory2D represents a 2D grid of nodes and heads
 type Memory2D struct {
-	mem  [][]*Nod
-	heds []*Hed
-	mu   sync.RWMutex // Protects concurrent access
+	mem      [][]*Nod
+	heds     []*Hed
+	hedsByID map[string]*Hed
+	mu       sync.RWMutex // Protects concurrent access
+	launcher *launcher    // Tick counter and quantised launches
+	scenes   map[string]*Scene
+	sceneMu  sync.Mutex // Scenes are saved from nod messages while mu is held
+	events   *eventBus  // Handlers registered with on
+	names    *nameIndex // Symbolic names for heds, nods and regions
+	layout   *layoutSetting
 }
 
 // NewMemory2D creates a new 2D memory grid
@@ -21,8 +30,14 @@ func NewMemory2D(rows, cols int) *Memory2D {
 	}
 
 	return &Memory2D{
-		mem:  mem,
-		heds: make([]*Hed, 0),
+		mem:      mem,
+		heds:     make([]*Hed, 0),
+		hedsByID: make(map[string]*Hed),
+		launcher: newLauncher(),
+		scenes:   make(map[string]*Scene),
+		events:   newEventBus(),
+		names:    newNameIndex(),
+		layout:   newLayoutSetting(),
 	}
 }
 
@@ -35,6 +50,11 @@ func (m *Memory2D) AddNod(x, y int, nod *Nod) error {
 		return fmt.Errorf("invalid coordinates: %w", err)
 	}
 
+	// A nod written over a named nod takes over its name
+	if old := m.mem[y][x]; old != nil && old.name != "" && nod.name == "" && old != nod {
+		m.NameNod(nod, old.name)
+	}
+
 	m.mem[y][x] = nod
 	return nil
 }
@@ -48,14 +68,18 @@ func (m *Memory2D) AddHed(x, y int, hed *Hed) error {
 		return fmt.Errorf("invalid coordinates: %w", err)
 	}
 
-	// Remove any existing head with the same ID
+	// Remove any existing head with the same ID, the new head inherits its name
 	for i := len(m.heds) - 1; i >= 0; i-- {
 		if m.heds[i].ID() == hed.ID() {
+			if old := m.heds[i]; old.name != "" && hed.name == "" && old != hed {
+				m.NameHed(hed, old.name)
+			}
 			m.heds = append(m.heds[:i], m.heds[i+1:]...)
 		}
 	}
 
 	m.heds = append(m.heds, hed)
+	m.hedsByID[hed.ID()] = hed
 	return nil
 }
 
@@ -85,25 +109,47 @@ func (m *Memory2D) GetHed(x, y int) (*Hed, error) {
 		return nil, fmt.Errorf("invalid coordinates: %w", err)
 	}
 
-	coordStr := fmt.Sprintf("%d,%d", x, y)
-	for _, hed := range m.heds {
-		if hed.ID() == coordStr {
-			return hed, nil
-		}
+	if hed, ok := m.hedsByID[HedID(x, y)]; ok {
+		return hed, nil
 	}
 
 	return nil, fmt.Errorf("no head at coordinates (%d,%d)", x, y)
 }
 
+// SetNodCall turns a nod into a subroutine call of the chain from first to
+// last. The grid is locked while it changes since heds follow calls as they
+// fire.
+func (m *Memory2D) SetNodCall(nod, first, last *Nod) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	nod.SetCall(first, last)
+}
+
+// ClearNodCall turns a subroutine nod back into a message nod, with the
+// grid locked as SetNodCall does
+func (m *Memory2D) ClearNodCall(nod *Nod) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	nod.ClearCall()
+}
+
 // Bang triggers all heads
 func (m *Memory2D) Bang() []error {
+	// Quantised launches land before the heds are banged so a hed started
+	// on a boundary fires on that boundary. This happens before taking the
+	// read lock because recalling a scene writes to the grid.
+	tick, errors := m.advanceLaunches()
+	for _, err := range errors {
+		connections.Publish(connections.Event{Type: connections.EventError, Tick: tick, Lines: []string{err.Error()}})
+	}
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	var errors []error
 	for _, hed := range m.heds {
-		if err := hed.Bang(); err != nil {
+		if err := hed.Bang(tick); err != nil {
 			errors = append(errors, fmt.Errorf("head %s error: %w", hed.ID(), err))
+			connections.Publish(connections.Event{Type: connections.EventError, Hed: hed.ID(), Tick: tick, Lines: []string{err.Error()}})
 		}
 	}
 
@@ -135,6 +181,12 @@ func HedID(x, y int) string {
 	return fmt.Sprintf("%d,%d", x, y)
 }
 
+// ParseID extracts the coordinates from a nod or hed id
+func ParseID(id string) (x, y int) {
+	fmt.Sscanf(id, "%d,%d", &x, &y)
+	return
+}
+
 func (m *Memory2D) GetHeads() []*Hed {
 	// No need for locking since we're just reading a snapshot
 	// and don't need perfect consistency for visualization
@@ -167,4 +219,11 @@ func (m *Memory2D) ClearMemory() {
 
 	// Clear heads slice
 	m.heds = make([]*Hed, 0)
+	m.hedsByID = make(map[string]*Hed)
+	m.forgetNames()
+
+	// Drop launches queued for heds that no longer exist
+	m.launcher.mu.Lock()
+	m.launcher.pending = nil
+	m.launcher.mu.Unlock()
 }
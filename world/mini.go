@@ -0,0 +1,472 @@
+// world/mini.go
+package world
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxMiniCycles is the longest alternation period a mini pattern may have
+// before it is rejected, since every cycle is laid out on the grid
+const maxMiniCycles = 16
+
+// Both the mini-notation rest and the usual seq rest are accepted
+const (
+	miniRest = "~"
+	seqRest  = "_"
+)
+
+// miniEvent is a single message scheduled inside a compiled pattern.
+// Time is measured in cycles from the start of the pattern.
+type miniEvent struct {
+	Time  *big.Rat
+	Value string
+}
+
+// MiniStep is a compiled nod, holding the message and the number of clock
+// ticks to wait before the next nod fires
+type MiniStep struct {
+	Message string
+	Ticks   int
+}
+
+// miniNode is a node of the parsed mini-notation tree
+type miniNode interface {
+	query(cycle int, start, span *big.Rat) []miniEvent
+	// size is the most steps a single cycle can query, stopping at limit+1
+	// so deeply nested patterns can't overflow
+	size(limit int) int
+}
+
+type miniWord struct {
+	value string
+}
+
+type miniSeq struct {
+	steps []miniNode
+}
+
+type miniFast struct {
+	node   miniNode
+	factor int
+}
+
+type miniAlt struct {
+	options []miniNode
+}
+
+type miniPoly struct {
+	seqs  []*miniSeq
+	steps int
+}
+
+func (w *miniWord) query(cycle int, start, span *big.Rat) []miniEvent {
+	if w.value == miniRest || w.value == seqRest {
+		return nil
+	}
+	return []miniEvent{{Time: new(big.Rat).Set(start), Value: w.value}}
+}
+
+// capSize keeps a step count from growing past limit+1
+func capSize(n, limit int) int {
+	if n > limit {
+		return limit + 1
+	}
+	return n
+}
+
+func (w *miniWord) size(limit int) int {
+	return 1
+}
+
+func (s *miniSeq) size(limit int) int {
+	total := 0
+	for _, step := range s.steps {
+		total = capSize(total+step.size(limit), limit)
+	}
+	return total
+}
+
+func (f *miniFast) size(limit int) int {
+	return capSize(f.factor*f.node.size(limit), limit)
+}
+
+func (a *miniAlt) size(limit int) int {
+	largest := 0
+	for _, option := range a.options {
+		if n := option.size(limit); n > largest {
+			largest = n
+		}
+	}
+	return largest
+}
+
+func (p *miniPoly) size(limit int) int {
+	steps := p.steps
+	if steps == 0 {
+		steps = len(p.seqs[0].steps)
+	}
+	layers := 0
+	for _, seq := range p.seqs {
+		largest := 0
+		for _, step := range seq.steps {
+			if n := step.size(limit); n > largest {
+				largest = n
+			}
+		}
+		layers = capSize(layers+largest, limit)
+	}
+	return capSize(steps*layers, limit)
+}
+
+func (s *miniSeq) query(cycle int, start, span *big.Rat) []miniEvent {
+	if len(s.steps) == 0 {
+		return nil
+	}
+	stepSpan := new(big.Rat).Quo(span, big.NewRat(int64(len(s.steps)), 1))
+	var events []miniEvent
+	for i, step := range s.steps {
+		offset := new(big.Rat).Mul(stepSpan, big.NewRat(int64(i), 1))
+		events = append(events, step.query(cycle, offset.Add(offset, start), stepSpan)...)
+	}
+	return events
+}
+
+func (f *miniFast) query(cycle int, start, span *big.Rat) []miniEvent {
+	stepSpan := new(big.Rat).Quo(span, big.NewRat(int64(f.factor), 1))
+	var events []miniEvent
+	for i := 0; i < f.factor; i++ {
+		offset := new(big.Rat).Mul(stepSpan, big.NewRat(int64(i), 1))
+		events = append(events, f.node.query(cycle*f.factor+i, offset.Add(offset, start), stepSpan)...)
+	}
+	return events
+}
+
+func (a *miniAlt) query(cycle int, start, span *big.Rat) []miniEvent {
+	return a.options[cycle%len(a.options)].query(cycle/len(a.options), start, span)
+}
+
+func (p *miniPoly) query(cycle int, start, span *big.Rat) []miniEvent {
+	steps := p.steps
+	if steps == 0 {
+		steps = len(p.seqs[0].steps)
+	}
+	if steps == 0 {
+		return nil
+	}
+	stepSpan := new(big.Rat).Quo(span, big.NewRat(int64(steps), 1))
+
+	var events []miniEvent
+	for i := 0; i < steps; i++ {
+		offset := new(big.Rat).Mul(stepSpan, big.NewRat(int64(i), 1))
+		offset.Add(offset, start)
+		for _, seq := range p.seqs {
+			k := len(seq.steps)
+			if k == 0 {
+				continue
+			}
+			n := cycle*steps + i
+			events = append(events, seq.steps[n%k].query(n/k, offset, stepSpan)...)
+		}
+	}
+	return events
+}
+
+// miniParser is a recursive descent parser over a mini-notation string.
+// No count and no cycle of the pattern may go past limit steps.
+type miniParser struct {
+	input string
+	pos   int
+	limit int
+}
+
+// parseMini parses a mini-notation pattern such as "bd*2 [sn sn] ~ <hh oh>",
+// refusing patterns with more than limit steps in a cycle
+func parseMini(input string, limit int) (miniNode, error) {
+	p := &miniParser{input: input, limit: limit}
+	seq, err := p.parseSequence("")
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return nil, p.errorf("unexpected %q", string(p.input[p.pos]))
+	}
+	if len(seq.steps) == 0 {
+		return nil, fmt.Errorf("mini: empty pattern")
+	}
+	if seq.size(limit) > limit {
+		return nil, fmt.Errorf("mini: pattern has more than %d steps in a cycle", limit)
+	}
+	return seq, nil
+}
+
+func (p *miniParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("mini: %s at column %d", fmt.Sprintf(format, args...), p.pos+1)
+}
+
+func (p *miniParser) skipSpace() {
+	for p.pos < len(p.input) && strings.ContainsRune(" \t\r\n", rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func isMiniSymbol(c byte) bool {
+	return strings.IndexByte("[]<>{},*!%", c) >= 0 || strings.IndexByte(" \t\r\n", c) >= 0
+}
+
+// parseSequence reads steps until one of the closing characters is reached
+func (p *miniParser) parseSequence(closers string) (*miniSeq, error) {
+	seq := &miniSeq{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.input) {
+			if closers != "" {
+				return nil, p.errorf("missing closing %q", closers[:1])
+			}
+			return seq, nil
+		}
+		c := p.input[p.pos]
+		if strings.IndexByte(closers, c) >= 0 {
+			return seq, nil
+		}
+		if c == ',' {
+			return nil, p.errorf("',' is only allowed inside { }")
+		}
+		if strings.IndexByte("]>}", c) >= 0 {
+			return nil, p.errorf("unmatched %q", string(c))
+		}
+
+		step, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+
+		// A ! suffix replicates the step, so it is expanded here rather than
+		// being treated as a node of its own
+		repeat := 1
+		for p.pos < len(p.input) && p.input[p.pos] == '!' {
+			p.pos++
+			if p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+				n, err := p.parseInt()
+				if err != nil {
+					return nil, err
+				}
+				repeat += n - 1
+			} else {
+				repeat++
+			}
+			if len(seq.steps)+repeat > p.limit {
+				return nil, p.errorf("more than %d steps", p.limit)
+			}
+		}
+		for i := 0; i < repeat; i++ {
+			seq.steps = append(seq.steps, step)
+		}
+	}
+}
+
+// parseStep reads an atom followed by any number of *n modifiers
+func (p *miniParser) parseStep() (miniNode, error) {
+	node, err := p.parseAtom()
+	if err != nil {
+		return nil, err
+	}
+	for p.pos < len(p.input) && p.input[p.pos] == '*' {
+		p.pos++
+		factor, err := p.parseInt()
+		if err != nil {
+			return nil, err
+		}
+		node = &miniFast{node: node, factor: factor}
+	}
+	return node, nil
+}
+
+func (p *miniParser) parseAtom() (miniNode, error) {
+	start := p.pos
+	switch p.input[p.pos] {
+	case '[':
+		p.pos++
+		seq, err := p.parseSequence("]")
+		if err != nil {
+			return nil, err
+		}
+		p.pos++
+		if len(seq.steps) == 0 {
+			p.pos = start
+			return nil, p.errorf("empty [ ]")
+		}
+		return seq, nil
+	case '<':
+		p.pos++
+		seq, err := p.parseSequence(">")
+		if err != nil {
+			return nil, err
+		}
+		p.pos++
+		if len(seq.steps) == 0 {
+			p.pos = start
+			return nil, p.errorf("empty < >")
+		}
+		return &miniAlt{options: seq.steps}, nil
+	case '{':
+		p.pos++
+		poly := &miniPoly{}
+		for {
+			seq, err := p.parseSequence(",}")
+			if err != nil {
+				return nil, err
+			}
+			poly.seqs = append(poly.seqs, seq)
+			if p.input[p.pos] == '}' {
+				p.pos++
+				break
+			}
+			p.pos++
+		}
+		if len(poly.seqs[0].steps) == 0 {
+			p.pos = start
+			return nil, p.errorf("empty { }")
+		}
+		if p.pos < len(p.input) && p.input[p.pos] == '%' {
+			p.pos++
+			steps, err := p.parseInt()
+			if err != nil {
+				return nil, err
+			}
+			poly.steps = steps
+		}
+		return poly, nil
+	case '*', '!', '%':
+		return nil, p.errorf("%q must follow a step", string(p.input[p.pos]))
+	}
+
+	for p.pos < len(p.input) && !isMiniSymbol(p.input[p.pos]) {
+		p.pos++
+	}
+	return &miniWord{value: p.input[start:p.pos]}, nil
+}
+
+// parseInt reads a positive integer used by *, ! and %, no larger than the
+// parser's limit
+func (p *miniParser) parseInt() (int, error) {
+	start := p.pos
+	for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, p.errorf("expected a number")
+	}
+	digits := p.input[start:p.pos]
+	n, err := strconv.Atoi(digits)
+	if errors.Is(err, strconv.ErrRange) || err == nil && n > p.limit {
+		p.pos = start
+		return 0, p.errorf("%s is more than %d steps", digits, p.limit)
+	}
+	if err != nil || n < 1 {
+		p.pos = start
+		return 0, p.errorf("expected a positive number")
+	}
+	return n, nil
+}
+
+// miniPeriod finds how many cycles it takes for a pattern to repeat
+func miniPeriod(node miniNode) (int, error) {
+	render := func(cycle int) string {
+		var b strings.Builder
+		for _, e := range node.query(cycle, new(big.Rat), big.NewRat(1, 1)) {
+			fmt.Fprintf(&b, "%s@%s ", e.Value, e.Time.RatString())
+		}
+		return b.String()
+	}
+
+	cycles := make([]string, 2*maxMiniCycles)
+	for i := range cycles {
+		cycles[i] = render(i)
+	}
+
+	for period := 1; period <= maxMiniCycles; period++ {
+		repeats := true
+		for i := 0; i+period < len(cycles) && repeats; i++ {
+			repeats = cycles[i] == cycles[i+period]
+		}
+		if repeats {
+			return period, nil
+		}
+	}
+	return 0, fmt.Errorf("mini: pattern does not repeat within %d cycles", maxMiniCycles)
+}
+
+// CompileMini turns a mini-notation pattern into a list of nod steps where
+// each cycle lasts ticksPerCycle clock ticks. A leading rest becomes a "_"
+// step so the first event keeps its place in the cycle. Rests after an event
+// are folded into that event's ticks. Patterns with more than maxSteps steps
+// in a cycle are refused.
+func CompileMini(input string, ticksPerCycle, maxSteps int) ([]MiniStep, error) {
+	if ticksPerCycle < 1 {
+		return nil, fmt.Errorf("mini: ticks per cycle must be positive, got %d", ticksPerCycle)
+	}
+
+	node, err := parseMini(input, maxSteps)
+	if err != nil {
+		return nil, err
+	}
+
+	period, err := miniPeriod(node)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []miniEvent
+	for cycle := 0; cycle < period; cycle++ {
+		start := big.NewRat(int64(cycle), 1)
+		events = append(events, node.query(cycle, start, big.NewRat(1, 1))...)
+	}
+
+	total := period * ticksPerCycle
+	if len(events) == 0 {
+		return []MiniStep{{Message: seqRest, Ticks: total}}, nil
+	}
+
+	// Layers of a polymeter can interleave, so order events by time before
+	// converting them to ticks
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Cmp(events[j].Time) < 0
+	})
+
+	// Events landing on the same tick share a nod, joined into one message
+	var messages []string
+	var ticks []int
+	for _, e := range events {
+		t := new(big.Rat).Mul(e.Time, big.NewRat(int64(ticksPerCycle), 1))
+		if !t.IsInt() {
+			return nil, fmt.Errorf("mini: event %q at cycle %s does not fall on a tick, use a multiple of %s ticks per cycle",
+				e.Value, e.Time.RatString(), e.Time.Denom().String())
+		}
+		tick := int(t.Num().Int64())
+		if len(ticks) > 0 && ticks[len(ticks)-1] == tick {
+			messages[len(messages)-1] += " " + e.Value
+			continue
+		}
+		messages = append(messages, e.Value)
+		ticks = append(ticks, tick)
+	}
+
+	var steps []MiniStep
+	if ticks[0] > 0 {
+		steps = append(steps, MiniStep{Message: seqRest, Ticks: ticks[0]})
+	}
+	for i, message := range messages {
+		next := total
+		if i+1 < len(ticks) {
+			next = ticks[i+1]
+		}
+		steps = append(steps, MiniStep{Message: message, Ticks: next - ticks[i]})
+	}
+	return steps, nil
+}
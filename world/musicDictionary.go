@@ -0,0 +1,324 @@
+// world/musicDictionary.go
+package world
+
+import (
+	"fmt"
+	"math"
+
+	"3body/forth"
+)
+
+// scales maps scale and mode names to semitone intervals above the root
+var scales = map[string][]int{
+	"major":            {0, 2, 4, 5, 7, 9, 11},
+	"ionian":           {0, 2, 4, 5, 7, 9, 11},
+	"dorian":           {0, 2, 3, 5, 7, 9, 10},
+	"phrygian":         {0, 1, 3, 5, 7, 8, 10},
+	"lydian":           {0, 2, 4, 6, 7, 9, 11},
+	"mixolydian":       {0, 2, 4, 5, 7, 9, 10},
+	"minor":            {0, 2, 3, 5, 7, 8, 10},
+	"aeolian":          {0, 2, 3, 5, 7, 8, 10},
+	"locrian":          {0, 1, 3, 5, 6, 8, 10},
+	"harmonic-minor":   {0, 2, 3, 5, 7, 8, 11},
+	"melodic-minor":    {0, 2, 3, 5, 7, 9, 11},
+	"pentatonic":       {0, 2, 4, 7, 9},
+	"major-pentatonic": {0, 2, 4, 7, 9},
+	"minor-pentatonic": {0, 3, 5, 7, 10},
+	"blues":            {0, 3, 5, 6, 7, 10},
+	"whole-tone":       {0, 2, 4, 6, 8, 10},
+	"chromatic":        {0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+}
+
+// chords maps chord qualities to semitone intervals above the root
+var chords = map[string][]int{
+	"maj":  {0, 4, 7},
+	"min":  {0, 3, 7},
+	"dim":  {0, 3, 6},
+	"aug":  {0, 4, 8},
+	"sus2": {0, 2, 7},
+	"sus4": {0, 5, 7},
+	"maj7": {0, 4, 7, 11},
+	"min7": {0, 3, 7, 10},
+	"dom7": {0, 4, 7, 10},
+	"7":    {0, 4, 7, 10},
+	"dim7": {0, 3, 6, 9},
+	"m7b5": {0, 3, 6, 10},
+	"add9": {0, 4, 7, 14},
+	"maj9": {0, 4, 7, 11, 14},
+	"min9": {0, 3, 7, 10, 14},
+}
+
+// noteValue reads a note from the stack, accepting MIDI numbers, note names
+// with an octave ("f#3") and bare pitch classes ("d"), which sit in octave 4
+func noteValue(item forth.StackItem) (float64, error) {
+	switch v := item.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case string:
+		if note, ok := forth.ParseNoteName(v); ok {
+			return note, nil
+		}
+		if pc, rest, ok := forth.ParsePitchClass(v); ok && rest == "" {
+			return float64(60 + pc), nil
+		}
+		return 0, fmt.Errorf("invalid note name %q", v)
+	default:
+		return 0, fmt.Errorf("value is not a note: %v", item)
+	}
+}
+
+// lookupScale returns the intervals for a scale name
+func lookupScale(name string) ([]int, error) {
+	intervals, ok := scales[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scale %q", name)
+	}
+	return intervals, nil
+}
+
+// degreeToNote maps a zero based scale degree to a note, moving up or down an
+// octave each time the degree passes the length of the scale
+func degreeToNote(root float64, intervals []int, degree int) float64 {
+	n := len(intervals)
+	octave := int(math.Floor(float64(degree) / float64(n)))
+	index := degree - octave*n
+	return root + float64(octave*12+intervals[index])
+}
+
+// quantizeToScale snaps a note to the nearest note in the scale, preferring
+// the lower note when two are equally close
+func quantizeToScale(note, root float64, intervals []int) float64 {
+	base := math.Floor((note-root)/12)*12 + root
+	best := note
+	bestDistance := math.Inf(1)
+	for octave := -1.0; octave <= 1; octave++ {
+		for _, interval := range intervals {
+			candidate := base + octave*12 + float64(interval)
+			distance := math.Abs(candidate - note)
+			if distance < bestDistance || (distance == bestDistance && candidate < best) {
+				best = candidate
+				bestDistance = distance
+			}
+		}
+	}
+	return best
+}
+
+// mapNotes applies fn to a single note or to every note of an array,
+// leaving rests and other non numeric messages untouched
+func mapNotes(item forth.StackItem, fn func(float64) float64) (forth.StackItem, error) {
+	switch v := item.(type) {
+	case float64:
+		return fn(v), nil
+	case int:
+		return fn(float64(v)), nil
+	case []interface{}:
+		mapped := make([]interface{}, len(v))
+		for i, elem := range v {
+			if n, ok := elem.(float64); ok {
+				mapped[i] = fn(n)
+			} else {
+				mapped[i] = elem
+			}
+		}
+		return mapped, nil
+	default:
+		return nil, fmt.Errorf("expected note or array, got %T", item)
+	}
+}
+
+// intervalsToArray builds an array of notes from a root and intervals
+func intervalsToArray(root float64, intervals []int) []interface{} {
+	arr := make([]interface{}, len(intervals))
+	for i, interval := range intervals {
+		arr[i] = root + float64(interval)
+	}
+	return arr
+}
+
+// DefineMusicDictionary creates forth words for notes, scales and chords
+func DefineMusicDictionary(memory *Memory2D) map[string]forth.DictionaryWord {
+	return map[string]forth.DictionaryWord{
+		// scale ( 'mode root -- array ) one octave of a scale from root
+		"scale": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if len(stack) < 2 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			s, rootItem, _ := forth.Pop(stack)
+			root, err := noteValue(rootItem)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			name, s, err := forth.PopString(s)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			intervals, err := lookupScale(name)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return forth.Push(s, intervalsToArray(root, intervals)), state, nil
+		},
+
+		// chord ( 'quality root -- array ) notes of a chord built on root
+		"chord": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if len(stack) < 2 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			s, rootItem, _ := forth.Pop(stack)
+			root, err := noteValue(rootItem)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			quality, s, err := forth.PopString(s)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			intervals, ok := chords[quality]
+			if !ok {
+				return stack, state, []string{fmt.Sprintf("Error: unknown chord %q", quality)}
+			}
+
+			return forth.Push(s, intervalsToArray(root, intervals)), state, nil
+		},
+
+		// transpose ( note|array semitones -- note|array ) shifts a note or every
+		// note in an array by semitones
+		"transpose": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if len(stack) < 2 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			semitones, newStack, err := forth.PopFloat(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			s, item, _ := forth.Pop(newStack)
+			result, err := mapNotes(item, func(n float64) float64 { return n + semitones })
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return forth.Push(s, result), state, nil
+		},
+
+		// degree->note ( degree|array -- note|array ) uses the current key and scale
+		"degree->note": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if len(stack) < 1 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			intervals, err := lookupScale(state.Scale)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			s, item, _ := forth.Pop(stack)
+			result, err := mapNotes(item, func(d float64) float64 {
+				return degreeToNote(state.Key, intervals, int(math.Floor(d)))
+			})
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return forth.Push(s, result), state, nil
+		},
+
+		// quantize-to-scale ( note|array -- note|array ) uses the current key and scale
+		"quantize-to-scale": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if len(stack) < 1 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			intervals, err := lookupScale(state.Scale)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			s, item, _ := forth.Pop(stack)
+			result, err := mapNotes(item, func(n float64) float64 {
+				return quantizeToScale(n, state.Key, intervals)
+			})
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return forth.Push(s, result), state, nil
+		},
+
+		// set-key ( root -- ) sets the key for this evaluation or hed
+		"set-key": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if len(stack) < 1 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			s, rootItem, _ := forth.Pop(stack)
+			root, err := noteValue(rootItem)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			newState := state
+			newState.Key = root
+			return s, newState, nil
+		},
+
+		// set-scale ( 'name -- ) sets the scale for this evaluation or hed
+		"set-scale": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			name, s, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			if _, err := lookupScale(name); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			newState := state
+			newState.Scale = name
+			return s, newState, nil
+		},
+
+		// hed-key ( y x|'name root 'scale -- y x|'name ) reharmonises running heds
+		"hed-key": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			name, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			if _, err := lookupScale(name); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			if len(newStack) == 0 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+			newStack, rootItem, _ := forth.Pop(newStack)
+			root, err := noteValue(rootItem)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			heds, ref, newStack, err := popHeds(memory, newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting hed: %v", err)}
+			}
+
+			for _, hed := range heds {
+				hed.SetKey(root, name)
+			}
+
+			return append(newStack, ref...), state, nil
+		},
+	}
+}
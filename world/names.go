@@ -0,0 +1,316 @@
+// world/names.go
+package world
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"3body/forth"
+)
+
+// Region is a labelled rectangle of the grid
+type Region struct {
+	Name   string `json:"name"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Contains reports whether a cell lies inside the region
+func (r Region) Contains(x, y int) bool {
+	return x >= r.X && x < r.X+r.Width && y >= r.Y && y < r.Y+r.Height
+}
+
+// nameIndex maps symbolic names to heds, nods and regions. It has its own
+// lock so names can be given from nod messages while Bang holds Memory2D.mu.
+type nameIndex struct {
+	heds    map[string]*Hed
+	nods    map[string]*Nod
+	regions map[string]Region
+	mu      sync.RWMutex
+}
+
+func newNameIndex() *nameIndex {
+	return &nameIndex{
+		heds:    make(map[string]*Hed),
+		nods:    make(map[string]*Nod),
+		regions: make(map[string]Region),
+	}
+}
+
+// NameHed gives a hed a name, taking it from any hed that already had it
+func (m *Memory2D) NameHed(hed *Hed, name string) error {
+	if name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+	n := m.names
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if old, ok := n.heds[name]; ok {
+		old.name = ""
+	}
+	if hed.name != "" {
+		delete(n.heds, hed.name)
+	}
+	hed.name = name
+	n.heds[name] = hed
+	return nil
+}
+
+// NameNod gives a nod a name, taking it from any nod that already had it
+func (m *Memory2D) NameNod(nod *Nod, name string) error {
+	if name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+	n := m.names
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if old, ok := n.nods[name]; ok {
+		old.name = ""
+	}
+	if nod.name != "" {
+		delete(n.nods, nod.name)
+	}
+	nod.name = name
+	n.nods[name] = nod
+	return nil
+}
+
+// DefineRegion labels a rectangle of the grid
+func (m *Memory2D) DefineRegion(name string, x, y, width, height int) error {
+	if name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+	if width < 1 || height < 1 {
+		return fmt.Errorf("region %s must be at least 1x1, got %dx%d", name, width, height)
+	}
+	if err := m.checkBounds(x, y); err != nil {
+		return err
+	}
+	if err := m.checkBounds(x+width-1, y+height-1); err != nil {
+		return err
+	}
+
+	m.names.mu.Lock()
+	defer m.names.mu.Unlock()
+	m.names.regions[name] = Region{Name: name, X: x, Y: y, Width: width, Height: height}
+	return nil
+}
+
+// HedByName looks up a named hed
+func (m *Memory2D) HedByName(name string) (*Hed, bool) {
+	m.names.mu.RLock()
+	defer m.names.mu.RUnlock()
+	hed, ok := m.names.heds[name]
+	return hed, ok
+}
+
+// NodByName looks up a named nod
+func (m *Memory2D) NodByName(name string) (*Nod, bool) {
+	m.names.mu.RLock()
+	defer m.names.mu.RUnlock()
+	nod, ok := m.names.nods[name]
+	return nod, ok
+}
+
+// RegionByName looks up a labelled region
+func (m *Memory2D) RegionByName(name string) (Region, bool) {
+	m.names.mu.RLock()
+	defer m.names.mu.RUnlock()
+	r, ok := m.names.regions[name]
+	return r, ok
+}
+
+// Regions returns every labelled region sorted by name
+func (m *Memory2D) Regions() []Region {
+	m.names.mu.RLock()
+	defer m.names.mu.RUnlock()
+
+	regions := make([]Region, 0, len(m.names.regions))
+	for _, r := range m.names.regions {
+		regions = append(regions, r)
+	}
+	sort.Slice(regions, func(i, j int) bool { return regions[i].Name < regions[j].Name })
+	return regions
+}
+
+// HedsInRegion returns the heds positioned inside a region
+func (m *Memory2D) HedsInRegion(r Region) []*Hed {
+	var heds []*Hed
+	for _, hed := range m.GetHeads() {
+		x, y := ParseID(hed.ID())
+		if r.Contains(x, y) {
+			heds = append(heds, hed)
+		}
+	}
+	return heds
+}
+
+// forgetNames drops names pointing at heds and nods that were cleared.
+// Regions are kept since they describe the grid rather than its contents.
+func (m *Memory2D) forgetNames() {
+	m.names.mu.Lock()
+	defer m.names.mu.Unlock()
+	m.names.heds = make(map[string]*Hed)
+	m.names.nods = make(map[string]*Nod)
+}
+
+// popHeds pops a hed reference, which is either a hed name, a region name
+// or y x coordinates. It returns the heds along with the popped items so
+// words can push them back for chaining.
+func popHeds(memory *Memory2D, stack forth.Stack) ([]*Hed, []forth.StackItem, forth.Stack, error) {
+	if len(stack) == 0 {
+		return nil, nil, stack, fmt.Errorf("stack underflow")
+	}
+
+	if name, ok := stack[len(stack)-1].(string); ok {
+		rest := stack[:len(stack)-1]
+		ref := []forth.StackItem{name}
+		if hed, ok := memory.HedByName(name); ok {
+			return []*Hed{hed}, ref, rest, nil
+		}
+		if region, ok := memory.RegionByName(name); ok {
+			return memory.HedsInRegion(region), ref, rest, nil
+		}
+		return nil, nil, stack, fmt.Errorf("no hed or region named %q", name)
+	}
+
+	if len(stack) < 2 {
+		return nil, nil, stack, fmt.Errorf("stack underflow")
+	}
+	ref := []forth.StackItem{stack[len(stack)-2], stack[len(stack)-1]}
+
+	x, rest, err := forth.PopInt(stack)
+	if err != nil {
+		return nil, nil, stack, err
+	}
+	y, rest, err := forth.PopInt(rest)
+	if err != nil {
+		return nil, nil, stack, err
+	}
+
+	hed, err := memory.GetHed(x, y)
+	if err != nil {
+		return nil, nil, stack, err
+	}
+	return []*Hed{hed}, ref, rest, nil
+}
+
+// popHed pops a reference that must resolve to exactly one hed
+func popHed(memory *Memory2D, stack forth.Stack) (*Hed, []forth.StackItem, forth.Stack, error) {
+	heds, ref, rest, err := popHeds(memory, stack)
+	if err != nil {
+		return nil, nil, stack, err
+	}
+	if len(heds) != 1 {
+		return nil, nil, stack, fmt.Errorf("expected one hed, %v refers to %d", ref[0], len(heds))
+	}
+	return heds[0], ref, rest, nil
+}
+
+// popNod pops a nod reference, either a nod name or y x coordinates
+func popNod(memory *Memory2D, stack forth.Stack) (*Nod, []forth.StackItem, forth.Stack, error) {
+	if len(stack) == 0 {
+		return nil, nil, stack, fmt.Errorf("stack underflow")
+	}
+
+	if name, ok := stack[len(stack)-1].(string); ok {
+		nod, ok := memory.NodByName(name)
+		if !ok {
+			return nil, nil, stack, fmt.Errorf("no nod named %q", name)
+		}
+		return nod, []forth.StackItem{name}, stack[:len(stack)-1], nil
+	}
+
+	if len(stack) < 2 {
+		return nil, nil, stack, fmt.Errorf("stack underflow")
+	}
+	ref := []forth.StackItem{stack[len(stack)-2], stack[len(stack)-1]}
+
+	x, rest, err := forth.PopInt(stack)
+	if err != nil {
+		return nil, nil, stack, err
+	}
+	y, rest, err := forth.PopInt(rest)
+	if err != nil {
+		return nil, nil, stack, err
+	}
+
+	nod, err := memory.GetNod(x, y)
+	if err != nil {
+		return nil, nil, stack, err
+	}
+	return nod, ref, rest, nil
+}
+
+// forgetHed drops the name of a removed hed
+func (m *Memory2D) forgetHed(hed *Hed) {
+	if hed.name == "" {
+		return
+	}
+	m.names.mu.Lock()
+	defer m.names.mu.Unlock()
+	if m.names.heds[hed.name] == hed {
+		delete(m.names.heds, hed.name)
+	}
+	hed.name = ""
+}
+
+// forgetNod drops the name of a removed nod
+func (m *Memory2D) forgetNod(nod *Nod) {
+	if nod.name == "" {
+		return
+	}
+	m.names.mu.Lock()
+	defer m.names.mu.Unlock()
+	if m.names.nods[nod.name] == nod {
+		delete(m.names.nods, nod.name)
+	}
+	nod.name = ""
+}
+
+// popRegion pops a region, either a region name or y x height width. The
+// name is "" for a plain rectangle.
+func popRegion(memory *Memory2D, stack forth.Stack) (Region, string, forth.Stack, error) {
+	if len(stack) == 0 {
+		return Region{}, "", stack, fmt.Errorf("stack underflow")
+	}
+
+	if name, ok := stack[len(stack)-1].(string); ok {
+		region, ok := memory.RegionByName(name)
+		if !ok {
+			return Region{}, "", stack, fmt.Errorf("no region named %q", name)
+		}
+		return region, name, stack[:len(stack)-1], nil
+	}
+
+	if len(stack) < 4 {
+		return Region{}, "", stack, fmt.Errorf("stack underflow")
+	}
+
+	width, rest, err := forth.PopInt(stack)
+	if err != nil {
+		return Region{}, "", stack, err
+	}
+	height, rest, err := forth.PopInt(rest)
+	if err != nil {
+		return Region{}, "", stack, err
+	}
+	x, rest, err := forth.PopInt(rest)
+	if err != nil {
+		return Region{}, "", stack, err
+	}
+	y, rest, err := forth.PopInt(rest)
+	if err != nil {
+		return Region{}, "", stack, err
+	}
+
+	if width < 1 || height < 1 {
+		return Region{}, "", stack, fmt.Errorf("region must be at least 1x1, got %dx%d", width, height)
+	}
+	return Region{X: x, Y: y, Width: width, Height: height}, "", rest, nil
+}
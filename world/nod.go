@@ -3,6 +3,7 @@ package world
 
 import (
 	"3body/forth"
+	"context"
 	"fmt"
 	"strings"
 )
@@ -16,8 +17,18 @@ const (
 
 type Nod struct {
 	id      string
+	name    string // Optional symbolic name
 	message Message
 	next    *Nod // Changed to pointer to Nod
+	ticks   int  // Clock ticks to wait after this nod fires, 0 uses the hed's every
+	sigils  *forth.SigilEnv
+	call    *nodCall // When set, banging the nod plays another chain instead
+}
+
+// nodCall is the chain a subroutine nod descends into
+type nodCall struct {
+	first *Nod
+	last  *Nod
 }
 
 func NewNod(id string, message Message) (*Nod, error) {
@@ -29,6 +40,7 @@ func NewNod(id string, message Message) (*Nod, error) {
 		id:      id,
 		message: message,
 		next:    nil, // Initialize with no next node
+		sigils:  forth.NewSigilEnv(),
 	}, nil
 }
 
@@ -36,6 +48,11 @@ func (n *Nod) ID() string {
 	return n.id
 }
 
+// Name returns the nod's symbolic name, "" if it has none
+func (n *Nod) Name() string {
+	return n.name
+}
+
 func (n *Nod) Next() *Nod {
 	return n.next
 }
@@ -44,6 +61,26 @@ func (n *Nod) SetNext(next *Nod) {
 	n.next = next
 }
 
+// SetCall turns the nod into a subroutine call of the chain from first to
+// last. A nil last plays the chain until it runs out of next nods.
+// Callers that don't hold the grid's lock use Memory2D.SetNodCall instead.
+func (n *Nod) SetCall(first, last *Nod) {
+	n.call = &nodCall{first: first, last: last}
+}
+
+// ClearCall turns a subroutine nod back into a message nod
+func (n *Nod) ClearCall() {
+	n.call = nil
+}
+
+// Call returns the chain a subroutine nod plays, first is nil for other nods
+func (n *Nod) Call() (first, last *Nod) {
+	if n.call == nil {
+		return nil, nil
+	}
+	return n.call.first, n.call.last
+}
+
 func (n *Nod) Message() Message {
 	return n.message
 }
@@ -52,7 +89,19 @@ func (n *Nod) SetMessage(message string) {
 	n.message = Message(message)
 }
 
-func (n *Nod) Bang(stack forth.Stack, state forth.State, modifier string) (forth.Stack, forth.State, []string, error) {
+// Ticks returns how long a hed waits after this nod before moving on
+func (n *Nod) Ticks() int {
+	return n.ticks
+}
+
+// SetTicks overrides the hed's every for this nod, 0 restores the default
+func (n *Nod) SetTicks(ticks int) {
+	n.ticks = ticks
+}
+
+// Bang evaluates the nod's message. ctx describes the hed running it and is
+// visible to words and sigils for the duration of the evaluation.
+func (n *Nod) Bang(stack forth.Stack, state forth.State, modifier string, ctx *forth.EvalContext) (forth.Stack, forth.State, []string, error) {
 	msg := string(n.message)
 
 	// Feels a bit hacky having this here but I dont know if theres a better way to solve this now that I am appending adddresses
@@ -65,13 +114,18 @@ func (n *Nod) Bang(stack forth.Stack, state forth.State, modifier string) (forth
 	}
 
 	// This allows for nodTime substitutions
-	msgWithSigils, err := forth.ParseSigils(msg)
+	msgWithSigils, err := forth.ExpandSigils(msg, n.sigils, ctx)
 
 	if err != nil {
-		return stack, state, nil, fmt.Errorf("error parsing sigil")
+		return stack, state, nil, fmt.Errorf("error parsing sigil: %w", err)
 	}
 
-	newStack, newState, output := forth.Interpret(msgWithSigils, stack, state)
+	state.Context = ctx
+	newStack, newState, output, err := forth.Evaluate(context.Background(), msgWithSigils, stack, state)
+	newState.Context = nil
+	if err != nil {
+		return newStack, newState, output, err
+	}
 
 	if len(output) > 0 && strings.HasPrefix(output[0], "Error:") {
 		return newStack, newState, nil, fmt.Errorf(output[0])
@@ -0,0 +1,228 @@
+// world/regionDictionary.go
+package world
+
+import (
+	"fmt"
+
+	"3body/forth"
+)
+
+// DefineRegionDictionary creates forth words for labelling and editing
+// rectangular regions of the grid. Words that take a region accept either a
+// region name or y x height width. The heds flag is 1 to bring the heds in
+// the region along and 0 to leave them.
+func DefineRegionDictionary(memory *Memory2D) map[string]forth.DictionaryWord {
+	// relabel keeps a named region on top of the nods it was moved with
+	relabel := func(name string, r Region) error {
+		if name == "" {
+			return nil
+		}
+		return memory.DefineRegion(name, r.X, r.Y, r.Width, r.Height)
+	}
+
+	return map[string]forth.DictionaryWord{
+		// region ( y x height width "name" -- ) labels a rectangle of the grid.
+		// Words that take a hed also take a region name and act on every hed
+		// inside it.
+		"region": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if len(stack) < 5 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			name, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			r, _, newStack, err := popRegion(memory, newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			if err := memory.DefineRegion(name, r.X, r.Y, r.Width, r.Height); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return newStack, state, nil
+		},
+
+		// region-clear ( region -- ) removes every hed and nod inside a region.
+		// A region's label is kept.
+		"region-clear": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if errs := refuseOnClock("region-clear", state); errs != nil {
+				return stack, state, errs
+			}
+
+			r, _, newStack, err := popRegion(memory, stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			if err := memory.ClearRegion(r); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return newStack, state, nil
+		},
+
+		// region-copy ( region destY destX heds -- ) pastes a copy of a region
+		// with its top left corner at destY destX
+		"region-copy": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if errs := refuseOnClock("region-copy", state); errs != nil {
+				return stack, state, errs
+			}
+
+			heds, destY, destX, newStack, err := popDest(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			r, _, newStack, err := popRegion(memory, newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			if err := memory.CopyRegion(r, destX, destY, heds); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return newStack, state, nil
+		},
+
+		// region-move ( region destY destX heds -- ) moves a region so its top
+		// left corner is at destY destX. A named region moves its label too.
+		"region-move": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if errs := refuseOnClock("region-move", state); errs != nil {
+				return stack, state, errs
+			}
+
+			heds, destY, destX, newStack, err := popDest(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			r, name, newStack, err := popRegion(memory, newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			moved, err := memory.MoveRegion(r, destX, destY, heds)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			if err := relabel(name, moved); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return newStack, state, nil
+		},
+
+		// region-flip-h ( region heds -- ) mirrors a region left to right
+		"region-flip-h": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if errs := refuseOnClock("region-flip-h", state); errs != nil {
+				return stack, state, errs
+			}
+
+			heds, newStack, err := forth.PopInt(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			r, _, newStack, err := popRegion(memory, newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			if err := memory.FlipRegion(r, true, heds != 0); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return newStack, state, nil
+		},
+
+		// region-flip-v ( region heds -- ) mirrors a region top to bottom
+		"region-flip-v": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if errs := refuseOnClock("region-flip-v", state); errs != nil {
+				return stack, state, errs
+			}
+
+			heds, newStack, err := forth.PopInt(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			r, _, newStack, err := popRegion(memory, newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			if err := memory.FlipRegion(r, false, heds != 0); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return newStack, state, nil
+		},
+
+		// region-rotate ( region turns heds -- ) rotates a region clockwise by
+		// quarter turns around its top left corner, negative turns go
+		// anticlockwise. A named region's label takes the new shape.
+		"region-rotate": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if errs := refuseOnClock("region-rotate", state); errs != nil {
+				return stack, state, errs
+			}
+
+			if len(stack) < 3 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			heds, newStack, err := forth.PopInt(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			turns, newStack, err := forth.PopInt(newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			r, name, newStack, err := popRegion(memory, newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			rotated, err := memory.RotateRegion(r, turns, heds != 0)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			if err := relabel(name, rotated); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return newStack, state, nil
+		},
+	}
+}
+
+// popDest pops the destY destX heds arguments shared by region-copy and
+// region-move
+func popDest(stack forth.Stack) (heds bool, destY, destX int, rest forth.Stack, err error) {
+	if len(stack) < 3 {
+		return false, 0, 0, stack, fmt.Errorf("stack underflow")
+	}
+
+	flag, rest, err := forth.PopInt(stack)
+	if err != nil {
+		return false, 0, 0, stack, err
+	}
+	destX, rest, err = forth.PopInt(rest)
+	if err != nil {
+		return false, 0, 0, stack, err
+	}
+	destY, rest, err = forth.PopInt(rest)
+	if err != nil {
+		return false, 0, 0, stack, err
+	}
+	return flag != 0, destY, destX, rest, nil
+}
@@ -0,0 +1,186 @@
+// world/remove.go
+package world
+
+import "fmt"
+
+// RemoveHed deletes the head at x y
+func (m *Memory2D) RemoveHed(x, y int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkBounds(x, y); err != nil {
+		return fmt.Errorf("invalid coordinates: %w", err)
+	}
+
+	hed, ok := m.hedsByID[HedID(x, y)]
+	if !ok {
+		return fmt.Errorf("no head at coordinates (%d,%d)", x, y)
+	}
+
+	m.removeHed(hed)
+	return nil
+}
+
+// RemoveNod deletes the nod at x y and repairs everything that pointed at
+// it. Nods that linked to it link to its next nod instead, or are detached
+// if it had none. Heds and subroutine nods that started on it start on its
+// next nod, and those that ended on it end on the nod before it.
+func (m *Memory2D) RemoveNod(x, y int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkBounds(x, y); err != nil {
+		return fmt.Errorf("invalid coordinates: %w", err)
+	}
+
+	if m.mem[y][x] == nil {
+		return fmt.Errorf("no node at coordinates (%d,%d)", x, y)
+	}
+
+	m.removeNod(x, y)
+	return nil
+}
+
+// ClearRegion removes every hed and nod inside a region
+func (m *Memory2D) ClearRegion(r Region) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkBounds(r.X, r.Y); err != nil {
+		return fmt.Errorf("invalid region: %w", err)
+	}
+	if err := m.checkBounds(r.X+r.Width-1, r.Y+r.Height-1); err != nil {
+		return fmt.Errorf("invalid region: %w", err)
+	}
+
+	for _, hed := range append([]*Hed(nil), m.heds...) {
+		if x, y := ParseID(hed.ID()); r.Contains(x, y) {
+			m.removeHed(hed)
+		}
+	}
+
+	for y := r.Y; y < r.Y+r.Height; y++ {
+		for x := r.X; x < r.X+r.Width; x++ {
+			if m.mem[y][x] != nil {
+				m.removeNod(x, y)
+			}
+		}
+	}
+	return nil
+}
+
+// removeHed takes a hed out of memory, the caller holds m.mu
+func (m *Memory2D) removeHed(hed *Hed) {
+	for i, h := range m.heds {
+		if h == hed {
+			m.heds = append(m.heds[:i], m.heds[i+1:]...)
+			break
+		}
+	}
+	if m.hedsByID[hed.ID()] == hed {
+		delete(m.hedsByID, hed.ID())
+	}
+	hed.Stop()
+	m.forgetHed(hed)
+	m.dropLaunches(hed)
+}
+
+// removeNod takes the nod at x y out of the grid and repairs links to it,
+// the caller holds m.mu
+func (m *Memory2D) removeNod(x, y int) {
+	nod := m.mem[y][x]
+	m.mem[y][x] = nil
+
+	next := nod.next
+	if next == nod {
+		next = nil
+	}
+
+	// Work out where windows ending on the nod should end before any links
+	// are changed, since the nod before it is found by walking the chain
+	hedLasts := make(map[*Hed]*Nod)
+	for _, hed := range m.heds {
+		if hed.last == nod {
+			hedLasts[hed] = precedingNod(hed.first, nod)
+		}
+	}
+	callLasts := make(map[*Nod]*Nod)
+	for _, row := range m.mem {
+		for _, n := range row {
+			if n != nil && n.call != nil && n.call.last == nod {
+				callLasts[n] = precedingNod(n.call.first, nod)
+			}
+		}
+	}
+
+	for _, row := range m.mem {
+		for _, n := range row {
+			if n == nil {
+				continue
+			}
+			if n.next == nod {
+				n.next = next
+			}
+			if n.call == nil {
+				continue
+			}
+			if last, ok := callLasts[n]; ok {
+				n.call.last = last
+			}
+			if n.call.first == nod {
+				n.call.first = next
+			}
+			if n.call.first == nil {
+				n.call = nil
+			}
+		}
+	}
+
+	for _, hed := range m.heds {
+		if last, ok := hedLasts[hed]; ok {
+			hed.last = last
+		}
+		if hed.first == nod {
+			hed.first = next
+		}
+
+		// A hed inside a subroutine that involved the nod gives up on it
+		for _, frame := range hed.calls {
+			if frame.site == nod || frame.last == nod {
+				hed.current = nod
+				hed.calls = nil
+				break
+			}
+		}
+
+		if hed.current == nod {
+			hed.current = next
+			if hed.current == nil {
+				hed.current = hed.first
+				hed.step = 0
+			}
+		}
+
+		// Nothing is left for the hed to play
+		if hed.first == nil {
+			hed.current = nil
+			hed.calls = nil
+			hed.Stop()
+		}
+	}
+
+	m.forgetNod(nod)
+}
+
+// precedingNod walks the chain from start and returns the nod that links to
+// target, or nil if target can't be reached
+func precedingNod(start, target *Nod) *Nod {
+	seen := make(map[*Nod]bool)
+	for n := start; n != nil && !seen[n]; n = n.next {
+		if n.next == target {
+			return n
+		}
+		seen[n] = true
+	}
+	return nil
+}
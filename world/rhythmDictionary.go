@@ -0,0 +1,328 @@
+// world/rhythmDictionary.go
+package world
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"3body/forth"
+)
+
+// Rhythm arrays use 1 for a hit and "_" for a rest so they can be passed
+// straight to seq, qs, qs-m and qs-lg.
+const (
+	rhythmHit  = float64(1)
+	rhythmRest = "_"
+)
+
+// maxNecklaceSteps caps necklace enumeration, which is exponential in steps
+const maxNecklaceSteps = 24
+
+// bjorklund distributes hits as evenly as possible over steps
+func bjorklund(hits, steps int) ([]bool, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("steps must be positive, got %d", steps)
+	}
+	if hits < 0 || hits > steps {
+		return nil, fmt.Errorf("hits must be between 0 and %d, got %d", steps, hits)
+	}
+
+	// Each group is a run of steps that will be concatenated at the end.
+	// Remainder groups are repeatedly folded onto the leading groups until
+	// at most one remainder group is left.
+	groups := make([][]bool, 0, steps)
+	for i := 0; i < steps; i++ {
+		groups = append(groups, []bool{i < hits})
+	}
+
+	head, tail := hits, steps-hits
+	for tail > 1 && head > 0 {
+		n := head
+		if tail < n {
+			n = tail
+		}
+		merged := make([][]bool, 0, len(groups)-n)
+		for i := 0; i < n; i++ {
+			merged = append(merged, append(groups[i], groups[len(groups)-n+i]...))
+		}
+		merged = append(merged, groups[n:len(groups)-n]...)
+		groups = merged
+
+		if head > tail {
+			head, tail = tail, head-tail
+		} else {
+			tail = tail - head
+		}
+	}
+
+	pattern := make([]bool, 0, steps)
+	for _, g := range groups {
+		pattern = append(pattern, g...)
+	}
+	return pattern, nil
+}
+
+// rotatePattern rotates a pattern left by n steps
+func rotatePattern(pattern []bool, n int) []bool {
+	if len(pattern) == 0 {
+		return pattern
+	}
+	n = ((n % len(pattern)) + len(pattern)) % len(pattern)
+	rotated := make([]bool, 0, len(pattern))
+	rotated = append(rotated, pattern[n:]...)
+	return append(rotated, pattern[:n]...)
+}
+
+// necklaces enumerates every binary necklace of the given length with
+// exactly hits ones. Each necklace is represented by its lexicographically
+// greatest rotation so patterns start on a hit.
+func necklaces(hits, steps int) ([][]bool, error) {
+	if steps <= 0 || steps > maxNecklaceSteps {
+		return nil, fmt.Errorf("steps must be between 1 and %d, got %d", maxNecklaceSteps, steps)
+	}
+	if hits < 0 || hits > steps {
+		return nil, fmt.Errorf("hits must be between 0 and %d, got %d", steps, hits)
+	}
+
+	var result [][]bool
+	for mask := (1 << steps) - 1; mask >= 0; mask-- {
+		pattern := make([]bool, steps)
+		count := 0
+		for i := 0; i < steps; i++ {
+			pattern[i] = mask&(1<<(steps-1-i)) != 0
+			if pattern[i] {
+				count++
+			}
+		}
+		if count != hits {
+			continue
+		}
+
+		// Only keep the mask if no rotation of it is greater
+		canonical := true
+		for r := 1; r < steps && canonical; r++ {
+			rotated := ((mask << r) | (mask >> (steps - r))) & ((1 << steps) - 1)
+			if rotated > mask {
+				canonical = false
+			}
+		}
+		if canonical {
+			result = append(result, pattern)
+		}
+	}
+	return result, nil
+}
+
+// parseRhythmLiteral reads a binary ("0b1001") or hex ("0x8a") rhythm.
+// Hex digits expand to four steps each, most significant bit first.
+func parseRhythmLiteral(literal string, base int) ([]bool, error) {
+	s := strings.ToLower(strings.TrimSpace(literal))
+	switch base {
+	case 2:
+		s = strings.TrimPrefix(s, "0b")
+	case 16:
+		s = strings.TrimPrefix(s, "0x")
+	}
+	if s == "" {
+		return nil, fmt.Errorf("empty rhythm literal %q", literal)
+	}
+
+	var pattern []bool
+	for _, c := range s {
+		if c == ' ' || c == '_' {
+			continue
+		}
+		digit, err := strconv.ParseUint(string(c), base, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid digit %q in rhythm literal %q", c, literal)
+		}
+		if base == 2 {
+			pattern = append(pattern, digit == 1)
+			continue
+		}
+		for bit := 3; bit >= 0; bit-- {
+			pattern = append(pattern, digit&(1<<bit) != 0)
+		}
+	}
+	return pattern, nil
+}
+
+// patternToArray converts a hit pattern into a forth array of hits and rests
+func patternToArray(pattern []bool) []interface{} {
+	arr := make([]interface{}, len(pattern))
+	for i, hit := range pattern {
+		if hit {
+			arr[i] = rhythmHit
+		} else {
+			arr[i] = rhythmRest
+		}
+	}
+	return arr
+}
+
+// DefineRhythmDictionary creates forth words that generate rhythm arrays
+func DefineRhythmDictionary() map[string]forth.DictionaryWord {
+	return map[string]forth.DictionaryWord{
+		// bjorklund ( hits steps -- array ) evenly distributes hits over steps
+		"bjorklund": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if len(stack) < 2 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			steps, newStack, err := forth.PopInt(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			hits, newStack, err := forth.PopInt(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			pattern, err := bjorklund(hits, steps)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return forth.Push(stack, patternToArray(pattern)), state, nil
+		},
+
+		// euclid ( hits steps rotation -- array ) euclidean rhythm rotated left
+		"euclid": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if len(stack) < 3 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			rotation, newStack, err := forth.PopInt(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			steps, newStack, err := forth.PopInt(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			hits, newStack, err := forth.PopInt(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			pattern, err := bjorklund(hits, steps)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return forth.Push(stack, patternToArray(rotatePattern(pattern, rotation))), state, nil
+		},
+
+		// necklace ( hits steps index -- array ) picks one of every distinct
+		// rhythm with hits hits in steps steps, wrapping the index
+		"necklace": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if len(stack) < 3 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			index, newStack, err := forth.PopInt(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			steps, newStack, err := forth.PopInt(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			hits, newStack, err := forth.PopInt(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			all, err := necklaces(hits, steps)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			index = ((index % len(all)) + len(all)) % len(all)
+			return forth.Push(stack, patternToArray(all[index])), state, nil
+		},
+
+		// hex-rhythm ( "0x8a" -- array ) four steps per hex digit
+		"hex-rhythm": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			literal, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			pattern, err := parseRhythmLiteral(literal, 16)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return forth.Push(stack, patternToArray(pattern)), state, nil
+		},
+
+		// bin-rhythm ( "10010010" -- array ) one step per binary digit
+		"bin-rhythm": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			literal, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			pattern, err := parseRhythmLiteral(literal, 2)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return forth.Push(stack, patternToArray(pattern)), state, nil
+		},
+
+		// rhythm-fill ( rhythm messages -- array ) replaces each hit with the
+		// next message, cycling through messages, and leaves rests in place
+		"rhythm-fill": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if len(stack) < 2 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			messages, newStack, err := forth.PopArray(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			rhythm, newStack, err := forth.PopArray(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			if len(messages) == 0 {
+				return stack, state, []string{"Error: rhythm-fill needs at least one message"}
+			}
+
+			filled := make([]interface{}, len(rhythm))
+			next := 0
+			for i, step := range rhythm {
+				if step == rhythmRest {
+					filled[i] = rhythmRest
+					continue
+				}
+				filled[i] = messages[next%len(messages)]
+				next++
+			}
+
+			return forth.Push(stack, filled), state, nil
+		},
+	}
+}
@@ -0,0 +1,282 @@
+// world/scene.go
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+
+	"3body/forth"
+)
+
+// Scene is a snapshot of every hed and nod. Nods are referenced by id rather
+// than pointer so scenes can be written to a file and restored after the
+// nods have been replaced.
+type Scene struct {
+	Name string     `json:"name"`
+	Heds []SceneHed `json:"heds"`
+	Nods []SceneNod `json:"nods"`
+}
+
+// SceneHed is the saved configuration of a single hed
+type SceneHed struct {
+	ID       string `json:"id"`
+	Stopped  bool   `json:"stopped"`
+	First    string `json:"first,omitempty"`
+	Last     string `json:"last,omitempty"`
+	Current  string `json:"current,omitempty"`
+	Every    int    `json:"every"`
+	Modifier string `json:"modifier,omitempty"`
+}
+
+// SceneNod is the saved contents of a single nod
+type SceneNod struct {
+	ID        string `json:"id"`
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
+	Message   string `json:"message"`
+	Ticks     int    `json:"ticks,omitempty"`
+	Next      string `json:"next,omitempty"`
+	CallFirst string `json:"callFirst,omitempty"` // First nod of the chain a subroutine nod plays
+	CallLast  string `json:"callLast,omitempty"`  // Last nod of that chain, "" plays it to the end
+}
+
+// nodRef returns the id of a nod, or "" when there is none
+func nodRef(nod *Nod) string {
+	if nod == nil {
+		return ""
+	}
+	return nod.ID()
+}
+
+// SaveScene captures the current heds and nods under a name
+func (m *Memory2D) SaveScene(name string) *Scene {
+	m.mu.RLock()
+	scene := &Scene{Name: name}
+	for y := range m.mem {
+		for x, nod := range m.mem[y] {
+			if nod == nil {
+				continue
+			}
+			first, last := nod.Call()
+			scene.Nods = append(scene.Nods, SceneNod{
+				ID:        nod.ID(),
+				X:         x,
+				Y:         y,
+				Message:   string(nod.Message()),
+				Ticks:     nod.Ticks(),
+				Next:      nodRef(nod.Next()),
+				CallFirst: nodRef(first),
+				CallLast:  nodRef(last),
+			})
+		}
+	}
+	for _, hed := range m.heds {
+		scene.Heds = append(scene.Heds, SceneHed{
+			ID:       hed.ID(),
+			Stopped:  hed.stopped,
+			First:    nodRef(hed.first),
+			Last:     nodRef(hed.last),
+			Current:  nodRef(hed.current),
+			Every:    hed.every,
+			Modifier: hed.modifier,
+		})
+	}
+	m.mu.RUnlock()
+
+	m.sceneMu.Lock()
+	defer m.sceneMu.Unlock()
+	m.scenes[name] = scene
+	return scene
+}
+
+// Scene returns a saved scene by name
+func (m *Memory2D) Scene(name string) (*Scene, error) {
+	m.sceneMu.Lock()
+	defer m.sceneMu.Unlock()
+
+	scene, ok := m.scenes[name]
+	if !ok {
+		return nil, fmt.Errorf("no scene named %q", name)
+	}
+	return scene, nil
+}
+
+// Scenes returns every saved scene, sorted by name
+func (m *Memory2D) Scenes() []*Scene {
+	m.sceneMu.Lock()
+	defer m.sceneMu.Unlock()
+
+	scenes := make([]*Scene, 0, len(m.scenes))
+	for _, scene := range m.scenes {
+		scenes = append(scenes, scene)
+	}
+	sort.Slice(scenes, func(i, j int) bool { return scenes[i].Name < scenes[j].Name })
+	return scenes
+}
+
+// SetScenes replaces the saved scenes, used when loading them from a file
+func (m *Memory2D) SetScenes(scenes []*Scene) {
+	m.sceneMu.Lock()
+	defer m.sceneMu.Unlock()
+
+	m.scenes = make(map[string]*Scene, len(scenes))
+	for _, scene := range scenes {
+		m.scenes[scene.Name] = scene
+	}
+}
+
+// WriteScenes writes every saved scene to a file as JSON
+func (m *Memory2D) WriteScenes(path string) (int, error) {
+	scenes := m.Scenes()
+	data, err := json.MarshalIndent(scenes, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	return len(scenes), os.WriteFile(path, data, 0o644)
+}
+
+// ReadScenes replaces the saved scenes with those in a file written by
+// WriteScenes. Nothing changes if the file can't be read.
+func (m *Memory2D) ReadScenes(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var scenes []*Scene
+	if err := json.Unmarshal(data, &scenes); err != nil {
+		return 0, fmt.Errorf("reading scenes from %s: %w", path, err)
+	}
+	for i, scene := range scenes {
+		if scene == nil || scene.Name == "" {
+			return 0, fmt.Errorf("reading scenes from %s: scene %d has no name", path, i+1)
+		}
+	}
+
+	m.SetScenes(scenes)
+	return len(scenes), nil
+}
+
+// RecallScene restores a saved scene. Nods missing from the grid are
+// recreated, heds missing from memory are created with state, and heds that
+// are not part of the scene are stopped. When prime is set, running heds
+// fire on the next bang so a quantised recall lands on the boundary.
+func (m *Memory2D) RecallScene(name string, state forth.State, prime bool) error {
+	scene, err := m.Scene(name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nods, err := m.restoreNods(scene.Nods)
+	if err != nil {
+		return err
+	}
+
+	inScene := make(map[string]bool, len(scene.Heds))
+	for _, saved := range scene.Heds {
+		inScene[saved.ID] = true
+
+		hed := m.hedsByID[saved.ID]
+		if hed == nil {
+			hed, err = NewHed(saved.ID, nil, nil, saved.Every, saved.Modifier, state)
+			if err != nil {
+				return fmt.Errorf("error recreating hed %s: %w", saved.ID, err)
+			}
+			m.heds = append(m.heds, hed)
+			m.hedsByID[hed.ID()] = hed
+		}
+
+		hed.first = nods[saved.First]
+		hed.last = nods[saved.Last]
+		hed.current = nods[saved.Current]
+		hed.calls = nil
+		hed.every = saved.Every
+		hed.modifier = saved.Modifier
+		hed.stopped = saved.Stopped
+		if prime {
+			hed.Prime()
+		}
+	}
+
+	for _, hed := range m.heds {
+		if !inScene[hed.ID()] {
+			hed.Stop()
+		}
+	}
+
+	return nil
+}
+
+// restoreNods writes saved nod contents back into the grid, then relinks
+// them and their subroutine calls. It returns the restored nods by id. Callers must hold m.mu.
+func (m *Memory2D) restoreNods(saved []SceneNod) (map[string]*Nod, error) {
+	nods := make(map[string]*Nod, len(saved))
+	for _, s := range saved {
+		if err := m.checkBounds(s.X, s.Y); err != nil {
+			return nil, fmt.Errorf("scene nod %s: %w", s.ID, err)
+		}
+		nod := m.mem[s.Y][s.X]
+		if nod == nil || nod.ID() != s.ID {
+			var err error
+			if nod, err = NewNod(s.ID, Message(s.Message)); err != nil {
+				return nil, err
+			}
+			m.mem[s.Y][s.X] = nod
+		}
+		nod.SetMessage(s.Message)
+		nod.SetTicks(s.Ticks)
+		nods[s.ID] = nod
+	}
+
+	for _, s := range saved {
+		nod := nods[s.ID]
+		nod.SetNext(nods[s.Next])
+		if first := nods[s.CallFirst]; first != nil {
+			nod.SetCall(first, nods[s.CallLast])
+		} else {
+			nod.ClearCall()
+		}
+	}
+	return nods, nil
+}
+
+// BlendScenes sets each nod's message from scene b with probability p and
+// from scene a otherwise. Heds are left untouched.
+func (m *Memory2D) BlendScenes(a, b string, p float64) error {
+	sceneA, err := m.Scene(a)
+	if err != nil {
+		return err
+	}
+	sceneB, err := m.Scene(b)
+	if err != nil {
+		return err
+	}
+
+	fromA := make(map[string]SceneNod, len(sceneA.Nods))
+	for _, s := range sceneA.Nods {
+		fromA[s.ID] = s
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range sceneB.Nods {
+		chosen := s
+		if saved, ok := fromA[s.ID]; ok && rand.Float64() >= p {
+			chosen = saved
+		}
+		if m.checkBounds(chosen.X, chosen.Y) != nil {
+			continue
+		}
+		if nod := m.mem[chosen.Y][chosen.X]; nod != nil {
+			nod.SetMessage(chosen.Message)
+		}
+	}
+	return nil
+}
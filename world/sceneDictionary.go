@@ -0,0 +1,155 @@
+// world/sceneDictionary.go
+package world
+
+import (
+	"fmt"
+	"strings"
+
+	"3body/forth"
+)
+
+// DefineSceneDictionary creates forth words for saving and recalling scenes
+func DefineSceneDictionary(memory *Memory2D) map[string]forth.DictionaryWord {
+	return map[string]forth.DictionaryWord{
+		// scene-save ( "name" -- ) snapshots every hed and nod
+		"scene-save": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			name, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			scene := memory.SaveScene(name)
+
+			return newStack, state, []string{fmt.Sprintf("saved scene %s: %d heds, %d nods", name, len(scene.Heds), len(scene.Nods))}
+		},
+
+		// scene-recall ( "name" -- ) restores a scene immediately, or on the
+		// next bang when used in a nod message
+		"scene-recall": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			name, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			// Bang holds the grid while nod messages run, so the recall
+			// waits for the next one
+			if state.Context != nil {
+				err = memory.QueueRecall(name, state)
+			} else {
+				err = memory.RecallScene(name, state, false)
+			}
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error recalling scene: %v", err)}
+			}
+
+			return newStack, state, nil
+		},
+
+		// scene-recall-q ( "name" -- ) restores a scene on the next launch boundary
+		"scene-recall-q": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			name, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			if err := memory.QueueScene(name, state); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error recalling scene: %v", err)}
+			}
+
+			return newStack, state, nil
+		},
+
+		// scene-blend ( "a" "b" probability -- ) takes each nod's message from
+		// scene b with the given probability, otherwise from scene a. Used in
+		// a nod message the blend happens on the next bang.
+		"scene-blend": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if len(stack) < 3 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			prob, newStack, err := forth.PopFloat(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			b, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			a, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			if state.Context != nil {
+				err = memory.QueueBlend(a, b, prob)
+			} else {
+				err = memory.BlendScenes(a, b, prob)
+			}
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error blending scenes: %v", err)}
+			}
+
+			return stack, state, nil
+		},
+
+		// scenes-save ( "file" -- ) writes every saved scene to a file, so
+		// they can be loaded again in a later session
+		"scenes-save": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			file, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			path, err := state.ResolvePath(file)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error saving scenes: %v", err)}
+			}
+
+			n, err := memory.WriteScenes(path)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error saving scenes: %v", err)}
+			}
+
+			return newStack, state, []string{fmt.Sprintf("saved %d scenes to %s", n, file)}
+		},
+
+		// scenes-load ( "file" -- ) replaces the saved scenes with those in a
+		// file written by scenes-save. The grid is left as it is until a
+		// scene is recalled.
+		"scenes-load": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			file, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			path, err := state.ResolvePath(file)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error loading scenes: %v", err)}
+			}
+
+			n, err := memory.ReadScenes(path)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error loading scenes: %v", err)}
+			}
+
+			return newStack, state, []string{fmt.Sprintf("loaded %d scenes from %s", n, file)}
+		},
+
+		// scene-list ( -- ) prints the saved scene names
+		"scene-list": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			var names []string
+			for _, scene := range memory.Scenes() {
+				names = append(names, scene.Name)
+			}
+			if len(names) == 0 {
+				return stack, state, []string{"<no scenes>"}
+			}
+			return stack, state, []string{strings.Join(names, " ")}
+		},
+	}
+}
@@ -0,0 +1,249 @@
+// world/transform.go
+package world
+
+import (
+	"fmt"
+
+	"3body/forth"
+)
+
+// cellMap gives the new position of a cell in a region
+type cellMap func(x, y int) (int, int)
+
+// CopyRegion duplicates the nods of a region at a new origin. Links between
+// nods inside the region are rewritten so the copy is an independent
+// sequence, links leaving the region still point at the original targets.
+// With heds set, heds inside the region are duplicated as well.
+func (m *Memory2D) CopyRegion(r Region, destX, destY int, heds bool) error {
+	return m.transformRegion(r, func(x, y int) (int, int) {
+		return destX + x - r.X, destY + y - r.Y
+	}, true, heds)
+}
+
+// MoveRegion moves the nods of a region to a new origin and returns the
+// region's new rectangle. Nods keep their links and names, so sequences
+// passing through the region follow it. With heds set, heds inside the
+// region move as well.
+func (m *Memory2D) MoveRegion(r Region, destX, destY int, heds bool) (Region, error) {
+	err := m.transformRegion(r, func(x, y int) (int, int) {
+		return destX + x - r.X, destY + y - r.Y
+	}, false, heds)
+	moved := r
+	moved.X, moved.Y = destX, destY
+	return moved, err
+}
+
+// FlipRegion mirrors a region in place, left to right when horizontal is
+// set and top to bottom otherwise
+func (m *Memory2D) FlipRegion(r Region, horizontal bool, heds bool) error {
+	return m.transformRegion(r, func(x, y int) (int, int) {
+		if horizontal {
+			return r.X + r.Width - 1 - (x - r.X), y
+		}
+		return x, r.Y + r.Height - 1 - (y - r.Y)
+	}, false, heds)
+}
+
+// RotateRegion turns a region clockwise by quarter turns around its top left
+// corner and returns its new rectangle, which swaps width and height for odd
+// turns
+func (m *Memory2D) RotateRegion(r Region, turns int, heds bool) (Region, error) {
+	turns = ((turns % 4) + 4) % 4
+	rotated := r
+	if turns%2 == 1 {
+		rotated.Width, rotated.Height = r.Height, r.Width
+	}
+
+	err := m.transformRegion(r, func(x, y int) (int, int) {
+		dx, dy := x-r.X, y-r.Y
+		w, h := r.Width, r.Height
+		for i := 0; i < turns; i++ {
+			dx, dy = h-1-dy, dx
+			w, h = h, w
+		}
+		return r.X + dx, r.Y + dy
+	}, false, heds)
+	return rotated, err
+}
+
+// transformRegion moves or copies every nod in a region to the cell given by
+// to, duplicating them instead of moving them when duplicate is set. Nods
+// already at a destination are removed with their links repaired.
+func (m *Memory2D) transformRegion(r Region, to cellMap, duplicate bool, withHeds bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r.Width < 1 || r.Height < 1 {
+		return fmt.Errorf("region must be at least 1x1, got %dx%d", r.Width, r.Height)
+	}
+	if err := m.checkBounds(r.X, r.Y); err != nil {
+		return fmt.Errorf("invalid region: %w", err)
+	}
+	if err := m.checkBounds(r.X+r.Width-1, r.Y+r.Height-1); err != nil {
+		return fmt.Errorf("invalid region: %w", err)
+	}
+
+	// Every destination is checked before anything changes
+	for y := r.Y; y < r.Y+r.Height; y++ {
+		for x := r.X; x < r.X+r.Width; x++ {
+			if err := m.checkBounds(to(x, y)); err != nil {
+				return fmt.Errorf("region doesn't fit: %w", err)
+			}
+		}
+	}
+
+	type placement struct {
+		nod  *Nod
+		x, y int
+	}
+	var placed []placement
+	mapped := make(map[*Nod]*Nod)
+	for y := r.Y; y < r.Y+r.Height; y++ {
+		for x := r.X; x < r.X+r.Width; x++ {
+			nod := m.mem[y][x]
+			if nod == nil {
+				continue
+			}
+			nx, ny := to(x, y)
+			target := nod
+			if duplicate {
+				target = &Nod{
+					message: nod.message,
+					next:    nod.next,
+					ticks:   nod.ticks,
+					sigils:  forth.NewSigilEnv(),
+				}
+				if nod.call != nil {
+					target.call = &nodCall{first: nod.call.first, last: nod.call.last}
+				}
+			}
+			target.id = NodID(nx, ny)
+			mapped[nod] = target
+			placed = append(placed, placement{nod: target, x: nx, y: ny})
+		}
+	}
+
+	var hedsIn []*Hed
+	if withHeds {
+		for _, hed := range m.heds {
+			if x, y := ParseID(hed.ID()); r.Contains(x, y) {
+				hedsIn = append(hedsIn, hed)
+			}
+		}
+	}
+
+	// Copies link to each other rather than to the originals
+	if duplicate {
+		for _, p := range placed {
+			if next, ok := mapped[p.nod.next]; ok {
+				p.nod.next = next
+			}
+			if p.nod.call != nil {
+				if first, ok := mapped[p.nod.call.first]; ok {
+					p.nod.call.first = first
+				}
+				if last, ok := mapped[p.nod.call.last]; ok {
+					p.nod.call.last = last
+				}
+			}
+		}
+	} else {
+		for y := r.Y; y < r.Y+r.Height; y++ {
+			for x := r.X; x < r.X+r.Width; x++ {
+				m.mem[y][x] = nil
+			}
+		}
+	}
+
+	// Clear the way, remembering what was removed so links the new nods
+	// carried into removed cells can skip past them
+	removed := make(map[*Nod]bool)
+	for _, p := range placed {
+		if old := m.mem[p.y][p.x]; old != nil {
+			removed[old] = true
+			m.removeNod(p.x, p.y)
+		}
+	}
+	for _, p := range placed {
+		p.nod.next = skipRemoved(p.nod.next, removed)
+		if p.nod.call != nil {
+			p.nod.call.first = skipRemoved(p.nod.call.first, removed)
+			p.nod.call.last = skipRemoved(p.nod.call.last, removed)
+			if p.nod.call.first == nil {
+				p.nod.call = nil
+			}
+		}
+		m.mem[p.y][p.x] = p.nod
+	}
+
+	// Moving heds leave their old ids first so heds swapping places don't
+	// replace each other
+	moving := make(map[*Hed]bool)
+	if !duplicate {
+		for _, hed := range hedsIn {
+			moving[hed] = true
+			if m.hedsByID[hed.id] == hed {
+				delete(m.hedsByID, hed.id)
+			}
+		}
+	}
+
+	for _, hed := range hedsIn {
+		x, y := ParseID(hed.ID())
+		nx, ny := to(x, y)
+		id := HedID(nx, ny)
+
+		target := hed
+		if duplicate {
+			target = &Hed{
+				first:      skipRemoved(mappedNod(mapped, hed.first), removed),
+				current:    skipRemoved(mappedNod(mapped, hed.current), removed),
+				last:       skipRemoved(mappedNod(mapped, hed.last), removed),
+				every:      hed.every,
+				stopped:    hed.stopped,
+				triggered:  hed.triggered,
+				stack:      forth.CreateStack(),
+				forthState: hed.forthState,
+				modifier:   hed.modifier,
+			}
+			if target.current == nil {
+				target.current = target.first
+			}
+			if target.first == nil {
+				target.stopped = true
+			}
+		}
+
+		if old, ok := m.hedsByID[id]; ok && !moving[old] {
+			m.removeHed(old)
+		}
+
+		target.id = id
+		if duplicate {
+			m.heds = append(m.heds, target)
+		}
+		m.hedsByID[id] = target
+	}
+	return nil
+}
+
+// mappedNod returns the copy of a nod if it was copied, otherwise the nod
+func mappedNod(mapped map[*Nod]*Nod, nod *Nod) *Nod {
+	if copied, ok := mapped[nod]; ok {
+		return copied
+	}
+	return nod
+}
+
+// skipRemoved follows links past nods that were removed from the grid
+func skipRemoved(nod *Nod, removed map[*Nod]bool) *Nod {
+	seen := make(map[*Nod]bool)
+	for nod != nil && removed[nod] && !seen[nod] {
+		seen[nod] = true
+		nod = nod.next
+	}
+	if removed[nod] {
+		return nil
+	}
+	return nod
+}
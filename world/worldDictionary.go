@@ -4,6 +4,7 @@ package world
 import (
 	"fmt"
 	"math/rand"
+	"strconv"
 
 	"3body/forth"
 
@@ -12,8 +13,35 @@ import (
 	"github.com/hypebeast/go-osc/osc"
 )
 
-// DefineWorldDictionary creates forth words that interact with the world
-func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) map[string]forth.DictionaryWord {
+// OSCSender sends osc messages, an *osc.Client or something standing in for
+// one
+type OSCSender interface {
+	Send(packet osc.Packet) error
+}
+
+// publishMessage reports a message sent out of the world, along with the hed
+// and nod that sent it if a hed is running
+func publishMessage(state forth.State, address, content string) {
+	e := connections.Event{Type: connections.EventMessage, Address: address, Lines: []string{content}}
+	if ctx := state.Context; ctx != nil {
+		e.Hed, e.Nod, e.Tick = ctx.HedID, ctx.NodID, ctx.Tick
+	}
+	connections.Publish(e)
+}
+
+// refuseOnClock returns an error line when a word that rewrites the grid is
+// used in a nod message. Bang holds the grid while nod messages run, so the
+// word would wait for it forever.
+func refuseOnClock(word string, state forth.State) []string {
+	if state.Context == nil {
+		return nil
+	}
+	return []string{fmt.Sprintf("Error: %s can't be used in a nod message", word)}
+}
+
+// DefineWorldDictionary creates forth words that interact with the world. A
+// nil client drops osc messages.
+func DefineWorldDictionary(memory *Memory2D, clock *Clock, client OSCSender) map[string]forth.DictionaryWord {
 	return map[string]forth.DictionaryWord{
 		// random ( -- n ) places a random number on stack
 		"random": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
@@ -42,7 +70,7 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 			return stack, state, nil
 		},
 
-		// m ( message address -- ) sends a message
+		// m-osc ( value "address" -- ) sends a number to an osc address
 		"m-osc": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			if len(stack) < 2 {
 				return stack, state, []string{"Error: stack underflow"}
@@ -63,14 +91,21 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 
 			oscMsg := osc.NewMessage(fmt.Sprintf("/%s", address))
 			oscMsg.Append(float32(message))
-			client.Send(oscMsg)
+			if client != nil {
+				client.Send(oscMsg)
+			}
+			publishMessage(state, "/"+address, strconv.FormatFloat(message, 'g', -1, 64))
 
 			return stack, state, nil
 		},
 
-		// Builds a linked sequence of nods from an array
-		// (arr y x -- y x)
+		// seq ( arr y x -- y x ) builds a linked sequence of nods from an
+		// array, laid out as set by seq-layout
 		"seq": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if errs := refuseOnClock("seq", state); errs != nil {
+				return stack, state, errs
+			}
+
 			if len(stack) < 2 {
 				return stack, state, []string{"Error: stack underflow"}
 			}
@@ -93,61 +128,145 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 			}
 			stack = newStack
 
-			// Reverses the array and then converts array vaule to a string
 			nodes := make([]*Nod, len(arr))
 			for i, val := range arr {
-
-				var message string
-				switch v := val.(type) {
-				case string:
-					message = v
-				case float64:
-					message = fmt.Sprintf("%g", v)
-				case int:
-					message = fmt.Sprintf("%d", v)
-				default:
-					message = fmt.Sprintf("%v", v)
-				}
-
-				// Special case, dont wrap
-				if val == "_" {
-					message = "_"
-				}
-
-				nod, err := NewNod(NodID(x+i, y), Message(message))
+				nod, err := NewNod(NodID(x+i, y), itemMessage(val))
 				if err != nil {
 					return stack, state, []string{fmt.Sprintf("error creating node: %v", err)}
 				}
 				nodes[i] = nod
 			}
 
-			// Then, set up the connections between nodes
-			for i := 0; i < len(nodes)-1; i++ {
-				nodes[i].SetNext(nodes[i+1])
+			// The whole sequence is checked before any of it is written
+			layout := memory.Layout()
+			replaced, err := memory.PlaceSequence(x, y, nodes, layout)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			stack = forth.Push(stack, y)
+			stack = forth.Push(stack, x)
+			return stack, state, placeReport(replaced, layout)
+		},
+
+		// seq-layout ( 'row|'column|'diagonal 'none|'wrap|'snake -- ) sets how
+		// seq, mini and the qs words lay out nods. wrap carries on from the
+		// start of the next row or column at the grid edge, snake turns back
+		// along it.
+		"seq-layout": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if len(stack) < 2 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			fold, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			direction, newStack, err := forth.PopString(newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			layout := memory.Layout()
+			layout.Direction, layout.Fold = direction, fold
+			if err := memory.SetLayout(layout); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return newStack, state, nil
+		},
+
+		// seq-overwrite ( 'allow|'report|'reject -- ) sets what happens when a
+		// sequence is written over existing nods
+		"seq-overwrite": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			mode, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			layout := memory.Layout()
+			layout.Overwrite = mode
+			if err := memory.SetLayout(layout); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return newStack, state, nil
+		},
+
+		// mini ( "pattern" ticksPerCycle y x -- y x ) builds a linked sequence
+		// of timed nods from a mini-notation pattern
+		"mini": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if errs := refuseOnClock("mini", state); errs != nil {
+				return stack, state, errs
+			}
+
+			if len(stack) < 4 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			x, newStack, err := forth.PopInt(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			y, newStack, err := forth.PopInt(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			ticks, newStack, err := forth.PopInt(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			pattern, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+			stack = newStack
+
+			// A pattern can't have more steps than the grid has cells
+			rows, cols := memory.Dimensions()
+			steps, err := CompileMini(pattern, ticks, rows*cols)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
 			}
 
-			// Finally, add all nodes to memory
-			for i, nod := range nodes {
-				err := memory.AddNod(x+i, y, nod)
+			nodes := make([]*Nod, len(steps))
+			for i, step := range steps {
+				nod, err := NewNod(NodID(x+i, y), Message(step.Message))
 				if err != nil {
-					return stack, state, []string{fmt.Sprintf("error adding node: %v", err)}
+					return stack, state, []string{fmt.Sprintf("error creating node: %v", err)}
 				}
+				nod.SetTicks(step.Ticks)
+				nodes[i] = nod
+			}
+
+			// The whole pattern is checked before any of it is written
+			layout := memory.Layout()
+			replaced, err := memory.PlaceSequence(x, y, nodes, layout)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
 			}
 
 			stack = forth.Push(stack, y)
 			stack = forth.Push(stack, x)
-			return stack, state, nil
+			return stack, state, placeReport(replaced, layout)
 		},
 
-		//array address every y x -- y x
-		// Deprectated, use namespaced qs-m
+		// qsm ( arr "address" every y x -- y x ) is deprecated, use qs-m
 		"qsm": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 
 			return forth.Interpret("qs-m", stack, state)
 
 		},
 
-		//array address every y x -- y x
+		// qs-m ( arr "address" every y x -- y x ) lays out a sequence at
+		// y x+1 with a hed at y x sending each value to an osc address
 		"qs-m": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			x, newStack, err := forth.PopInt(stack)
 			if err != nil {
@@ -179,6 +298,11 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 			}
 			stack = newStack
 
+			// The sequence and its hed are checked before anything is written
+			if err := checkQuickSeq(memory, len(arr), y, x); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
 			stack = forth.Push(stack, arr)
 			stack = forth.Push(stack, y)
 			stack = forth.Push(stack, x+1)
@@ -190,6 +314,8 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 			return stack, state, message
 		},
 
+		// qs-lg ( arr every y x -- y x ) lays out a sequence at y x+1 with a
+		// hed at y x sending each message to the line graphics
 		"qs-lg": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			x, newStack, err := forth.PopInt(stack)
 			if err != nil {
@@ -215,6 +341,11 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 			}
 			stack = newStack
 
+			// The sequence and its hed are checked before anything is written
+			if err := checkQuickSeq(memory, len(arr), y, x); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
 			stack = forth.Push(stack, arr)
 			stack = forth.Push(stack, y)
 			stack = forth.Push(stack, x+1)
@@ -224,6 +355,8 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 			return stack, state, message
 		},
 
+		// qs-hg ( arr every y x -- y x ) lays out a sequence at y x+1 with a
+		// hed at y x sending each message to hydra
 		"qs-hg": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			x, newStack, err := forth.PopInt(stack)
 			if err != nil {
@@ -249,6 +382,11 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 			}
 			stack = newStack
 
+			// The sequence and its hed are checked before anything is written
+			if err := checkQuickSeq(memory, len(arr), y, x); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
 			stack = forth.Push(stack, arr)
 			stack = forth.Push(stack, y)
 			stack = forth.Push(stack, x+1)
@@ -258,9 +396,8 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 			return stack, state, message
 		},
 
-		// [ array of js commands ] stitch
-		// sitiches function calls with a '.' in between and sends them as
-		// used for chaining js calls e.g. one().two().three()
+		// stitch ( arr -- "code" ) joins js calls with a '.' in between for
+		// chaining them e.g. one().two().three()
 		"stitch": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			arr, newStack, err := forth.PopArray(stack)
 			if err != nil {
@@ -285,12 +422,14 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 			return stack, state, nil
 		},
 
+		// hydra ( arr -- ) stitches js calls together and sends them to hydra
 		"hydra": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			stack, state, message := forth.Interpret("stitch m-hg", stack, state)
 			return stack, state, message
 		},
 
-		// [ array of commands ] freq y x
+		// qs ( arr every y x -- y x ) lays out a sequence at y x+1 with a hed
+		// at y x running each message as it is
 		"qs": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			x, newStack, err := forth.PopInt(stack)
 			if err != nil {
@@ -316,6 +455,11 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 			}
 			stack = newStack
 
+			// The sequence and its hed are checked before anything is written
+			if err := checkQuickSeq(memory, len(arr), y, x); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
 			stack = forth.Push(stack, arr)
 			stack = forth.Push(stack, y)
 			stack = forth.Push(stack, x+1)
@@ -380,10 +524,13 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 			return forth.Interpret(msg2, stack, state)
 		},
 
+		// _ ( -- ) does nothing, a rest in a sequence
 		"_": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			return stack, state, nil
 		},
 
+		// nod ( y x nextY nextX -- nextY nextX ) creates a nod at y x linked
+		// to the nod at nextY nextX
 		"nod": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			if len(stack) < 4 {
 				return stack, state, []string{"Error: stack underflow"}
@@ -438,42 +585,132 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 			return stack, state, nil
 		},
 
-		"r-m": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
-			if len(stack) < 3 {
-				return stack, state, []string{"Error: stack underflow"}
+		// nod-name ( y x|'name "name" -- y x|'name ) names a nod so it can be
+		// addressed without coordinates
+		"nod-name": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			name, newStack, err := forth.PopString(stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			nod, ref, newStack, err := popNod(memory, newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting nod: %v", err)}
+			}
+
+			if err := memory.NameNod(nod, name); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+			}
+
+			return append(newStack, ref...), state, nil
+		},
+
+		// nod-remove ( y x|'name -- ) deletes a nod. Nods that linked to it
+		// link past it and heds that were on it move to the next nod.
+		"nod-remove": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if errs := refuseOnClock("nod-remove", state); errs != nil {
+				return stack, state, errs
+			}
+
+			nod, _, newStack, err := popNod(memory, stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting nod: %v", err)}
+			}
+
+			x, y := ParseID(nod.ID())
+			if err := memory.RemoveNod(x, y); err != nil {
+				return stack, state, []string{fmt.Sprintf("Error removing nod: %v", err)}
 			}
 
+			return newStack, state, nil
+		},
+
+		// r-m ( y x|'name "message" -- y x|'name ) replaces a nod's message
+		"r-m": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			message, newStack, err := forth.PopString(stack)
 			if err != nil {
 				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
 			}
-			stack = newStack
 
-			x, newStack, err := forth.PopInt(stack)
+			nod, ref, newStack, err := popNod(memory, newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting nod: %v", err)}
+			}
+
+			nod.SetMessage(message)
+
+			return append(newStack, ref...), state, nil
+		},
+
+		// nod-call ( y x first last -- y x ) makes the nod at y x play the
+		// chain from first to last and then return. first and last are y x
+		// coordinates or nod names.
+		"nod-call": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if errs := refuseOnClock("nod-call", state); errs != nil {
+				return stack, state, errs
+			}
+
+			last, _, newStack, err := popNod(memory, stack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting last nod: %v", err)}
+			}
+
+			first, _, newStack, err := popNod(memory, newStack)
+			if err != nil {
+				return stack, state, []string{fmt.Sprintf("Error getting first nod: %v", err)}
+			}
+
+			if len(newStack) < 2 {
+				return stack, state, []string{"Error: stack underflow"}
+			}
+
+			x, newStack, err := forth.PopInt(newStack)
 			if err != nil {
 				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
 			}
-			stack = newStack
 
-			y, newStack, err := forth.PopInt(stack)
+			y, newStack, err := forth.PopInt(newStack)
 			if err != nil {
 				return stack, state, []string{fmt.Sprintf("Error: %v", err)}
 			}
-			stack = newStack
 
-			nod, err := memory.GetNod(int(x), int(y))
+			// Reuse an existing nod so links into it are kept
+			nod, err := memory.GetNod(x, y)
+			if err != nil {
+				nod, err = NewNod(NodID(x, y), "_")
+				if err != nil {
+					return stack, state, []string{fmt.Sprintf("Error: %v", err)}
+				}
+				if err := memory.AddNod(x, y, nod); err != nil {
+					return stack, state, []string{fmt.Sprintf("Error adding nod: %v", err)}
+				}
+			}
+
+			memory.SetNodCall(nod, first, last)
+
+			newStack = append(newStack, float64(y))
+			newStack = append(newStack, float64(x))
+			return newStack, state, nil
+		},
+
+		// nod-uncall ( y x|'name -- y x|'name ) turns a subroutine nod back into
+		// a message nod
+		"nod-uncall": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			if errs := refuseOnClock("nod-uncall", state); errs != nil {
+				return stack, state, errs
+			}
+
+			nod, ref, newStack, err := popNod(memory, stack)
 			if err != nil {
 				return stack, state, []string{fmt.Sprintf("Error getting nod: %v", err)}
 			}
 
-			nod.SetMessage(message)
+			memory.ClearNodCall(nod)
 
-			stack = append(stack, float64(y))
-			stack = append(stack, float64(x))
-			return stack, state, nil
+			return append(newStack, ref...), state, nil
 		},
 
-		// Message hydra graphics
+		// m-lg ( "message" -- ) sends a message to the line graphics
 		"m-lg": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			if len(stack) < 1 {
 				return stack, state, []string{"Error: stack underflow"}
@@ -488,11 +725,12 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 			if connections.HTTPMessageChannel != nil {
 				connections.HTTPMessageChannel <- connections.HTTPMessage{Type: "line", Content: msg}
 			}
+			publishMessage(state, "line", msg)
 
 			return newStack, state, nil
 		},
 
-		// Message line graphics
+		// m-hg ( "message" -- ) sends a message to hydra
 		"m-hg": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			if len(stack) < 1 {
 				return stack, state, []string{"Error: stack underflow"}
@@ -507,10 +745,25 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 			if connections.HTTPMessageChannel != nil {
 				connections.HTTPMessageChannel <- connections.HTTPMessage{Type: "hydra", Content: msg}
 			}
+			publishMessage(state, "hydra", msg)
 
 			return newStack, state, nil
 		},
 
+		// analyze ( -- ) reports unreachable nods, loops, stale links and heds
+		// whose last nod can't be reached
+		"analyze": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
+			a := memory.Analyze()
+
+			output := []string{fmt.Sprintf("%d nods, %d heds, %d nods reachable, %d issues", a.Nods, a.Heds, a.Reachable, len(a.Issues))}
+			for _, issue := range a.Issues {
+				output = append(output, fmt.Sprintf("%s: %s", issue.Kind, issue.Message))
+			}
+
+			return stack, state, output
+		},
+
+		// clear-memory ( -- ) removes every hed and nod
 		"clear-memory": func(stack forth.Stack, state forth.State) (forth.Stack, forth.State, []string) {
 			memory.ClearMemory()
 
@@ -519,3 +772,12 @@ func DefineWorldDictionary(memory *Memory2D, clock *Clock, client *osc.Client) m
 	}
 
 }
+
+// checkQuickSeq makes sure a qs word's sequence at y x+1 and its hed at y x
+// both fit before either is written
+func checkQuickSeq(memory *Memory2D, n, y, x int) error {
+	if err := memory.checkBounds(x, y); err != nil {
+		return fmt.Errorf("hed doesn't fit: %w", err)
+	}
+	return memory.CheckSequence(x+1, y, n, memory.Layout())
+}